@@ -6,22 +6,55 @@
 package monetdb
 
 import (
-	"time"
+	"crypto/tls"
 
 	"github.com/MonetDB/MonetDB-Go/v2/mapi"
 )
 
+// Config contains the settings of a connection. The settings that can be
+// given in the DSN are part of the embedded mapi.Config, so FormatDSN
+// returns the DSN for this configuration.
 type Config struct {
-	AutoCommit bool
-	ReplySize  int
-	Sizeheader bool
-	Timezone   *time.Location
+	mapi.Config
+	TLSConfig  *tls.Config
+	ClientCert *tls.Certificate
+	Uploader   mapi.Uploader
+	Downloader mapi.Downloader
+
+	MessageHandler mapi.MessageHandler
+
+	// Tracer records the protocol messages of the connections, Replay
+	// serves a recorded transcript instead of the server
+	Tracer *mapi.Tracer
+	Replay *mapi.Replay
+
+	// DecimalAsFloat returns the decimals as float64, like older versions
+	// of the driver, instead of their exact value
+	DecimalAsFloat bool
+
+	// NativeTypes returns the values of uuid, inet, url and json columns
+	// as mapi.UUID, netip.Prefix, *url.URL and json.RawMessage instead of
+	// strings
+	NativeTypes bool
 }
 
 func (cfg Config) DefaultConfig() Config {
-	cfg.AutoCommit = true
-	cfg.ReplySize = mapi.MAPI_ARRAY_SIZE
-	cfg.Sizeheader = true
-	cfg.Timezone = time.Local
+	cfg.Config = mapi.DefaultConfig()
 	return cfg
 }
+
+// ParseDSN returns the configuration for the given data source name. All
+// settings that are not specified in the DSN get their default value.
+func ParseDSN(dsn string) (Config, error) {
+	c, err := mapi.ParseDSN(dsn)
+	if err != nil {
+		return Config{}, err
+	}
+	return Config{Config: c}, nil
+}
+
+// useTLS reports whether any of the TLS settings is configured. Using one of
+// them implies that the connection must be encrypted.
+func (cfg Config) useTLS() bool {
+	return cfg.TLS || cfg.TLSConfig != nil || cfg.CertHash != "" || cfg.ClientCert != nil
+}
@@ -9,43 +9,162 @@ import (
 	"database/sql"
 	"database/sql/driver"
 	"fmt"
+	"io"
+	"time"
 
 	"github.com/MonetDB/MonetDB-Go/v2/mapi"
 )
 
 type Conn struct {
-	mapi mapi.MapiConn
+	mapi       mapi.MapiConn
+	uploader   mapi.Uploader
+	downloader mapi.Downloader
+
+	decimalAsFloat bool
+	nativeTypes    bool
 }
 
-func newConn(name string, cfg Config) (*Conn, error) {
+func newConn(ctx context.Context, cfg Config) (*Conn, error) {
 	conn := &Conn{
 		mapi: nil,
 	}
 
-	m, err := mapi.NewMapi(name)
-	if err != nil {
-		return conn, err
-	}
-	errConn := m.Connect()
+	m := mapi.NewMapiWithConfig(cfg.Config)
+	m.TLS = cfg.useTLS()
+	m.TLSConfig = cfg.TLSConfig
+	m.ClientCert = cfg.ClientCert
+	m.MessageHandler = cfg.MessageHandler
+	m.Tracer = cfg.Tracer
+	m.Replay = cfg.Replay
+	conn.uploader = cfg.Uploader
+	conn.downloader = cfg.Downloader
+	conn.decimalAsFloat = cfg.DecimalAsFloat
+	conn.nativeTypes = cfg.NativeTypes
+	// The session settings of the configuration are applied during the
+	// login, when one of them fails the connection is closed
+	errConn := m.ConnectContext(ctx)
 	if errConn != nil {
 		return conn, errConn
 	}
 
 	conn.mapi = m
-	// TODO: handle return values
-	m.SetAutoCommit(cfg.AutoCommit)
-	m.SetReplySize(cfg.ReplySize)
-	m.SetSizeHeader(cfg.Sizeheader)
-	m.SetServerTimezone(cfg.Timezone)
 	return conn, nil
 }
 
+// Endpoint returns the server that the connection is logged in to, after
+// following the redirects. Use sql.Conn.Raw to call it:
+//
+//	conn.Raw(func(driverConn any) error {
+//		endpoint := driverConn.(*monetdb.Conn).Endpoint()
+//		...
+//	})
+func (c *Conn) Endpoint() mapi.Endpoint {
+	return c.mapi.Endpoint()
+}
+
+// Messages returns the info and warning messages that the server sent for
+// the last statement on the connection. Use sql.Conn.Raw to call it:
+//
+//	conn.Raw(func(driverConn any) error {
+//		for _, msg := range driverConn.(*monetdb.Conn).Messages() {
+//			...
+//		}
+//	})
+func (c *Conn) Messages() []mapi.Message {
+	return c.mapi.Messages()
+}
+
+// Time that the server gets to stop an interrupted statement, before the
+// connection is closed
+var interruptTimeout = 10 * time.Second
+
+// cancel interrupts the statement that is running on the server, after the
+// context of the statement is cancelled. It returns when the goroutine that
+// executes the statement is finished, so the connection can be used again.
+// The interruptTimeout includes the time it takes to interrupt. When the
+// command is not sent yet, there is nothing to interrupt and the connection
+// is closed right away.
+func (c *Conn) cancel(done <-chan struct{}) {
+	select {
+	case <-done:
+		return
+	default:
+	}
+	timeout := time.After(interruptTimeout)
+	if err := c.mapi.Interrupt(); err == nil {
+		select {
+		case <-done:
+			return
+		case <-timeout:
+		}
+	}
+	// The statement could not be stopped. Closing the connection makes the
+	// goroutine return.
+	c.mapi.Abort()
+	<-done
+}
+
+// setDeadline applies the deadline of the statement context to the network
+// connection. The deadline is extended by the interruptTimeout, so a
+// cancelled statement is normally stopped by the server first, and the
+// connection can be reused. The deadline only takes over when the server is
+// not responding at all.
+func (c *Conn) setDeadline(ctx context.Context) {
+	d, ok := ctx.Deadline()
+	if ok {
+		d = d.Add(interruptTimeout)
+	}
+	c.mapi.SetDeadline(d)
+}
+
 func (c *Conn) Prepare(query string) (driver.Stmt, error) {
-	return newStmt(c, query, true), nil
+	return c.PrepareContext(context.Background(), query)
+}
+
+// Ping checks that the server responds, with a statement that makes a round
+// trip. A broken connection is reported with driver.ErrBadConn, so the pool
+// replaces it.
+func (c *Conn) Ping(ctx context.Context) error {
+	if !c.IsValid() {
+		return driver.ErrBadConn
+	}
+	stmt := newStmt(c, "SELECT 1", false)
+	defer stmt.Close()
+	if _, err := stmt.ExecContext(ctx, nil); err != nil {
+		if !c.IsValid() {
+			return driver.ErrBadConn
+		}
+		return err
+	}
+	return nil
+}
+
+// ResetSession is called by the pool before the connection is used again. It
+// rolls back a transaction that is still open and restores the session
+// settings of the connector, like the autocommit mode, the reply size, the
+// time zone and the schema.
+func (c *Conn) ResetSession(ctx context.Context) error {
+	if !c.IsValid() {
+		return driver.ErrBadConn
+	}
+	c.setDeadline(ctx)
+	if err := c.mapi.ResetSession(); err != nil {
+		// The state of the session is unknown, so it must not be used again
+		return driver.ErrBadConn
+	}
+	return nil
+}
+
+// IsValid reports whether the connection can be used again. It is false after
+// an error of the network connection, then the pool closes the connection.
+func (c *Conn) IsValid() bool {
+	return c.mapi != nil && c.mapi.Valid()
 }
 
+// Close ends the session. The Xrelease and Xclose commands that are still
+// waiting for the next command are not sent, the server releases the
+// prepared statements and result sets of a session when it ends.
 func (c *Conn) Close() error {
-	// TODO: close prepared statements
 	c.mapi.Disconnect()
 	c.mapi = nil
 	return nil
@@ -113,12 +232,36 @@ func (c *Conn) QueryContext(ctx context.Context, query string, args []driver.Nam
 	return res, err
 }
 
-func (c *Conn) PrepareContext(ctx context.Context, query string) (Stmt, error) {
+// PrepareContext prepares the statement on the server, which reports the
+// number and the types of the placeholders
+func (c *Conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
 	stmt := newStmt(c, query, true)
-	return *stmt, nil
+	_, err := stmt.mapiDo(ctx, func() (string, error) {
+		return "", stmt.prepare(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stmt, nil
 }
 
 func (c *Conn) CheckNamedValue(arg *driver.NamedValue) error {
+	return checkNamedValue(arg)
+}
+
+// checkNamedValue verifies that an argument can be converted. An io.Reader
+// for a BLOB is read into memory here, converting it would consume it. The
+// statement holds the blob as hexadecimal digits, so an argument needs about
+// three times its size in memory.
+func checkNamedValue(arg *driver.NamedValue) error {
+	if r, ok := arg.Value.(io.Reader); ok {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		arg.Value = data
+		return nil
+	}
 	_, err := mapi.ConvertToMonet(arg.Value)
 	return err
 }
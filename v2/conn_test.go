@@ -0,0 +1,272 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package monetdb
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MonetDB/MonetDB-Go/v2/mapitest"
+)
+
+// poolServer records the sessions of the statements, a CRASH statement
+// closes the connection
+func poolServer(t *testing.T) (*mapitest.Server, func(statement string) []int) {
+	var mu sync.Mutex
+	sessions := make(map[string][]int)
+	srv := mapitest.NewServer(mapitest.HandlerFunc(func(r *mapitest.Request) mapitest.Reply {
+		mu.Lock()
+		sessions[r.Statement] = append(sessions[r.Statement], r.Session)
+		mu.Unlock()
+		switch {
+		case r.Statement == "CRASH":
+			return mapitest.Hangup{}
+		case strings.HasPrefix(r.Statement, "PREPARE"):
+			return mapitest.Prepared{ID: 7, Params: []string{"int"}}
+		case strings.HasPrefix(r.Statement, "SELECT i"):
+			return mapitest.Table{Columns: []string{"i"}, Types: []string{"int"}, Rows: [][]string{{"1"}, {"2"}, {"3"}}}
+		case strings.HasPrefix(r.Statement, "SELECT s.name FROM sys.schemas"):
+			return mapitest.Table{Columns: []string{"name"}, Types: []string{"varchar"}, Rows: [][]string{{"\"sys\""}}}
+		case strings.HasPrefix(r.Statement, "SET"):
+			return mapitest.Schema{}
+		default:
+			return mapitest.Update{Count: 1, LastID: -1}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func(statement string) []int {
+		mu.Lock()
+		defer mu.Unlock()
+		return sessions[statement]
+	}
+}
+
+func openPool(t *testing.T, srv *mapitest.Server) *sql.DB {
+	db, err := sql.Open("monetdb", srv.DSN()+"?replysize=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestConnPool(t *testing.T) {
+	t.Run("Verify ping makes a round trip", func(t *testing.T) {
+		srv, sessions := poolServer(t)
+		db := openPool(t, srv)
+		if err := db.Ping(); err != nil {
+			t.Fatal(err)
+		}
+		if len(sessions("SELECT 1")) != 1 {
+			t.Errorf("Unexpected statements: %q", srv.Statements())
+		}
+	})
+
+	t.Run("Verify broken connection is replaced", func(t *testing.T) {
+		srv, sessions := poolServer(t)
+		db := openPool(t, srv)
+		if _, err := db.Exec("CRASH"); err == nil {
+			t.Fatal("Expected an error")
+		}
+		if _, err := db.Exec("INSERT INTO t VALUES (1)"); err != nil {
+			t.Fatal(err)
+		}
+		crashed, next := sessions("CRASH"), sessions("INSERT INTO t VALUES (1)")
+		if len(crashed) != 1 || len(next) != 1 || crashed[0] == next[0] {
+			t.Errorf("The broken connection is used again: %v, %v", crashed, next)
+		}
+	})
+
+	t.Run("Verify session is reset for the next user", func(t *testing.T) {
+		srv, sessions := poolServer(t)
+		db := openPool(t, srv)
+		if _, err := db.Exec("SET SCHEMA tmp"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec("INSERT INTO t VALUES (1)"); err != nil {
+			t.Fatal(err)
+		}
+		changed, reset := sessions("SET SCHEMA tmp"), sessions("SET SCHEMA \"sys\"")
+		if len(reset) != 1 || reset[0] != changed[0] {
+			t.Errorf("The schema is not reset: %q", srv.Statements())
+		}
+	})
+	t.Run("Verify closed statement is released", func(t *testing.T) {
+		srv, _ := poolServer(t)
+		db := openPool(t, srv)
+		stmt, err := db.Prepare("INSERT INTO t VALUES (?)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := stmt.Exec(1); err != nil {
+			t.Fatal(err)
+		}
+		stmt.Close()
+		if _, err := db.Exec("INSERT INTO t VALUES (2)"); err != nil {
+			t.Fatal(err)
+		}
+		if !hasCommand(srv.Commands(), "release 7") {
+			t.Errorf("The statement is not released: %q", srv.Commands())
+		}
+	})
+
+	t.Run("Verify unread rows are closed", func(t *testing.T) {
+		srv, _ := poolServer(t)
+		db := openPool(t, srv)
+		rows, err := db.Query("SELECT i FROM t")
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows.Next()
+		rows.Close()
+		if _, err := db.Exec("INSERT INTO t VALUES (1)"); err != nil {
+			t.Fatal(err)
+		}
+		if !hasCommand(srv.Commands(), "close ") {
+			t.Errorf("The result set is not closed: %q", srv.Commands())
+		}
+	})
+}
+
+// hasCommand reports whether one of the commands starts with prefix
+func hasCommand(commands []string, prefix string) bool {
+	for _, command := range commands {
+		if strings.HasPrefix(command, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestConnCancel(t *testing.T) {
+	t.Run("Verify cancel before the command is sent closes the connection", func(t *testing.T) {
+		srv := mapitest.NewServer(mapitest.HandlerFunc(func(r *mapitest.Request) mapitest.Reply {
+			// A long running statement, it only ends when the client
+			// disconnects
+			<-r.Context().Done()
+			return mapitest.Error{Code: "HY008", Message: "Query aborted"}
+		}))
+		t.Cleanup(srv.Close)
+		db := openPool(t, srv)
+		conn, err := db.Conn(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		start := time.Now()
+		err = conn.Raw(func(driverConn interface{}) error {
+			c := driverConn.(*Conn)
+			s := newStmt(c, "SELECT * FROM long_running", false)
+			_, err := s.mapiDo(ctx, func() (string, error) {
+				// The context is cancelled before the command is written
+				<-ctx.Done()
+				time.Sleep(50 * time.Millisecond)
+				return s.exec(context.Background(), nil)
+			})
+			if c.IsValid() {
+				t.Error("Expected the connection to be closed")
+			}
+			return err
+		})
+		if err != context.Canceled {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed >= interruptTimeout/2 {
+			t.Errorf("Cancel waited for the interrupt timeout: %v", elapsed)
+		}
+	})
+	t.Run("Verify cancel while the next block of rows is fetched", func(t *testing.T) {
+		srv := mapitest.NewServer(mapitest.HandlerFunc(func(r *mapitest.Request) mapitest.Reply {
+			return mapitest.Table{Columns: []string{"i"}, Types: []string{"int"}, Rows: [][]string{{"1"}, {"2"}, {"3"}, {"4"}}}
+		}))
+		t.Cleanup(srv.Close)
+		addr := stallingProxy(t, srv.Addr(), "Xexport")
+		db, err := sql.Open("monetdb", "monetdb://monetdb:monetdb@"+addr+"/demo?replysize=2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		rows, err := db.QueryContext(ctx, "SELECT i FROM t")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		for i := 0; i < 2; i++ {
+			if !rows.Next() {
+				t.Fatal(rows.Err())
+			}
+		}
+		time.AfterFunc(50*time.Millisecond, cancel)
+		next := make(chan bool, 1)
+		go func() { next <- rows.Next() }()
+		select {
+		case ok := <-next:
+			if ok || rows.Err() != context.Canceled {
+				t.Errorf("Unexpected end of rows: %v, %v", ok, rows.Err())
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Fetching the rows is not cancelled")
+		}
+	})
+}
+
+// stallingProxy forwards the connections to addr, until the client sends a
+// message that contains stall. After that the messages of the client are
+// dropped, so the client waits for an answer that never comes.
+func stallingProxy(t *testing.T, addr string, stall string) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			client, err := l.Accept()
+			if err != nil {
+				return
+			}
+			server, err := net.Dial("tcp", addr)
+			if err != nil {
+				client.Close()
+				return
+			}
+			t.Cleanup(func() {
+				client.Close()
+				server.Close()
+			})
+			go io.Copy(client, server)
+			go func() {
+				buf := make([]byte, 8192)
+				stalled := false
+				for {
+					n, err := client.Read(buf)
+					if err != nil {
+						server.Close()
+						return
+					}
+					stalled = stalled || bytes.Contains(buf[:n], []byte(stall))
+					if !stalled {
+						server.Write(buf[:n])
+					}
+				}
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
@@ -7,20 +7,27 @@ package monetdb
 
 import (
 	"context"
+	"crypto/tls"
 	"database/sql/driver"
+	"io"
 	"time"
+
+	"github.com/MonetDB/MonetDB-Go/v2/mapi"
 )
 
 type Connector struct {
-	name string
-	cfg  Config
+	cfg Config
 }
 
 func NewConnector(name string, options ...connectorOption) (*Connector, error) {
+	cfg, err := ParseDSN(name)
+	if err != nil {
+		return nil, err
+	}
+
 	connector := &Connector{
-		name: name,
+		cfg: cfg,
 	}
-	connector.cfg = connector.cfg.DefaultConfig()
 	for _, opt := range options {
 		opt(&connector.cfg)
 	}
@@ -28,8 +35,8 @@ func NewConnector(name string, options ...connectorOption) (*Connector, error) {
 	return connector, nil
 }
 
-func (c *Connector) Connect(context.Context) (driver.Conn, error) {
-	return newConn(c.name, c.cfg)
+func (c *Connector) Connect(ctx context.Context) (driver.Conn, error) {
+	return newConn(ctx, c.cfg)
 }
 
 func (c *Connector) Driver() driver.Driver {
@@ -61,3 +68,160 @@ func TimezoneOption(timezone *time.Location) connectorOption {
 		c.Timezone = timezone
 	}
 }
+
+func SchemaOption(schema string) connectorOption {
+	return func(c *Config) {
+		c.Schema = schema
+	}
+}
+
+// SocketOption connects through the Unix domain socket at the given path, for
+// example /tmp/.s.monetdb.50000. When the socket does not exist, the hostname
+// and port from the DSN are used instead.
+func SocketOption(path string) connectorOption {
+	return func(c *Config) {
+		c.Socket = path
+	}
+}
+
+// ConnectTimeoutOption limits the time it takes to open a connection and log
+// in, including any redirects. When it expires, the error is a
+// *mapi.TimeoutError.
+func ConnectTimeoutOption(timeout time.Duration) connectorOption {
+	return func(c *Config) {
+		c.ConnectTimeout = timeout
+	}
+}
+
+// ReadTimeoutOption limits the time the driver waits for each block of data
+// from the server. A query that takes longer to produce its result fails with
+// a *mapi.TimeoutError and the connection is closed.
+func ReadTimeoutOption(timeout time.Duration) connectorOption {
+	return func(c *Config) {
+		c.ReadTimeout = timeout
+	}
+}
+
+// WriteTimeoutOption limits the time it takes to send each block of data to
+// the server.
+func WriteTimeoutOption(timeout time.Duration) connectorOption {
+	return func(c *Config) {
+		c.WriteTimeout = timeout
+	}
+}
+
+// MaxRedirectsOption sets the number of times the login may be redirected,
+// for example by monetdbd, before the connection fails
+func MaxRedirectsOption(redirects int) connectorOption {
+	return func(c *Config) {
+		c.MaxRedirects = redirects
+	}
+}
+
+// StopSessionOption lets a cancelled statement be stopped with sys.stop from
+// a separate connection, when out-of-band interrupts are not available, like
+// on a TLS connection or an older server. The id of the session is asked
+// together with the first statement. Without it such a connection is closed
+// when its statement is cancelled.
+func StopSessionOption(enable bool) connectorOption {
+	return func(c *Config) {
+		c.StopSession = enable
+	}
+}
+
+// PasswordHashOption logs in with the hash of the password instead of the
+// password itself, in the form <algorithm>:<hexdigits>. The algorithm must be
+// the one the server uses to store passwords, normally SHA512. Use
+// mapi.HashPassword to create the value.
+func PasswordHashOption(passwordHash string) connectorOption {
+	return func(c *Config) {
+		c.PasswordHash = passwordHash
+	}
+}
+
+// TLSConfigOption encrypts the connection using the given TLS configuration,
+// for example to trust a private certificate authority through RootCAs.
+func TLSConfigOption(tlsConfig *tls.Config) connectorOption {
+	return func(c *Config) {
+		c.TLSConfig = tlsConfig
+	}
+}
+
+// CertHashOption encrypts the connection and only accepts a server certificate
+// whose SHA-256 hash starts with the given hex digits, in the form
+// sha256:<hexdigits>. This replaces the verification against trusted roots.
+func CertHashOption(certHash string) connectorOption {
+	return func(c *Config) {
+		c.CertHash = certHash
+	}
+}
+
+// ClientCertOption encrypts the connection and presents the given client
+// certificate to the server.
+func ClientCertOption(cert tls.Certificate) connectorOption {
+	return func(c *Config) {
+		c.ClientCert = &cert
+	}
+}
+
+// UploaderOption registers the handler for COPY INTO ... ON CLIENT statements.
+// Use WithUploader to use a different handler for a single statement.
+func UploaderOption(uploader mapi.Uploader) connectorOption {
+	return func(c *Config) {
+		c.Uploader = uploader
+	}
+}
+
+// DownloaderOption registers the handler for COPY ... INTO ... ON CLIENT
+// statements. Use WithDownloader to use a different handler for a single
+// statement.
+func DownloaderOption(downloader mapi.Downloader) connectorOption {
+	return func(c *Config) {
+		c.Downloader = downloader
+	}
+}
+
+// MessageHandlerOption registers a handler for the info and warning messages
+// of the server. It receives the messages of all connections, together with
+// the statement that caused them.
+func MessageHandlerOption(handler mapi.MessageHandler) connectorOption {
+	return func(c *Config) {
+		c.MessageHandler = handler
+	}
+}
+
+// TraceOption writes a transcript of the messages that the connections
+// exchange with the server to w, which is useful to report a problem with
+// the protocol. The passwords are left out. See mapi.Tracer for the format.
+func TraceOption(w io.Writer) connectorOption {
+	return func(c *Config) {
+		c.Tracer = mapi.NewTracer(w)
+	}
+}
+
+// ReplayOption connects to a transcript that was written with TraceOption,
+// instead of the server. The recorded connections are served in order, the
+// statements must be the same as in the transcript.
+func ReplayOption(transcript io.Reader) connectorOption {
+	return func(c *Config) {
+		c.Replay = mapi.NewReplay(transcript)
+	}
+}
+
+// DecimalAsFloatOption returns the values of DECIMAL columns as float64, like
+// older versions of the driver did. By default they are returned exactly, as
+// text that can be scanned into a string or a mapi.Decimal.
+func DecimalAsFloatOption(enable bool) connectorOption {
+	return func(c *Config) {
+		c.DecimalAsFloat = enable
+	}
+}
+
+// NativeTypesOption returns the values of UUID, INET, URL and JSON columns as
+// a mapi.UUID, a netip.Prefix, a *url.URL and a json.RawMessage. By default
+// they are returned as text.
+func NativeTypesOption(enable bool) connectorOption {
+	return func(c *Config) {
+		c.NativeTypes = enable
+	}
+}
@@ -6,9 +6,14 @@
 package monetdb
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"testing"
+	"time"
+
+	"github.com/MonetDB/MonetDB-Go/v2/mapi"
 )
 
 func TestConnectorDefaultIntegration(t *testing.T) {
@@ -146,12 +151,12 @@ func TestConnectorDefaultIntegration(t *testing.T) {
 			scantype := column.ScanType()
 			// Not every type has a name. Then the name is the empty string. In that case, compare the types
 			if scantype.Name() != "" {
-				if scantype.Name() != "float64" {
-					t.Errorf("unexpected scan type: %s instead of %s", "float64", scantype.Name())
+				if scantype.Name() != "Decimal" {
+					t.Errorf("unexpected scan type: %s instead of %s", "Decimal", scantype.Name())
 				}
 			} else {
-				if fmt.Sprintf("%v", scantype) != "float64" {
-					t.Errorf("unexpected scan type: %s instead of %v", "float64", scantype)
+				if fmt.Sprintf("%v", scantype) != "mapi.Decimal" {
+					t.Errorf("unexpected scan type: %s instead of %v", "mapi.Decimal", scantype)
 				}
 			}
 			precision, scale, ok := column.DecimalSize()
@@ -330,12 +335,12 @@ func TestConnectorSizeheaderFalseIntegration(t *testing.T) {
 			scantype := column.ScanType()
 			// Not every type has a name. Then the name is the empty string. In that case, compare the types
 			if scantype.Name() != "" {
-				if scantype.Name() != "float64" {
-					t.Errorf("unexpected scan type: %s instead of %s", "float64", scantype.Name())
+				if scantype.Name() != "Decimal" {
+					t.Errorf("unexpected scan type: %s instead of %s", "Decimal", scantype.Name())
 				}
 			} else {
-				if fmt.Sprintf("%v", scantype) != "float64" {
-					t.Errorf("unexpected scan type: %s instead of %v", "float64", scantype)
+				if fmt.Sprintf("%v", scantype) != "mapi.Decimal" {
+					t.Errorf("unexpected scan type: %s instead of %v", "mapi.Decimal", scantype)
 				}
 			}
 			// In this case, the value for ok might not be what you expect. It means that precision and scale
@@ -383,3 +388,110 @@ func TestConnectorSizeheaderFalseIntegration(t *testing.T) {
 		}
 	})
 }
+
+func TestConnectorTimeoutIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	t.Run("Read timeout", func(t *testing.T) {
+		connector, err := NewConnector("monetdb:monetdb@localhost:50000/monetdb", ReadTimeoutOption(200*time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		db := sql.OpenDB(connector)
+		defer db.Close()
+
+		_, err = db.Exec("select count(*) from sys.generate_series(0, 100000) a, sys.generate_series(0, 100000) b")
+		var terr *mapi.TimeoutError
+		if !errors.As(err, &terr) {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Connect timeout", func(t *testing.T) {
+		connector, err := NewConnector("monetdb:monetdb@10.255.255.1:50000/monetdb", ConnectTimeoutOption(200*time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		db := sql.OpenDB(connector)
+		defer db.Close()
+
+		err = db.Ping()
+		var terr *mapi.TimeoutError
+		if !errors.As(err, &terr) {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+}
+
+func TestConnectorEndpointIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	connector, err := NewConnector("monetdb:monetdb@localhost:50000/monetdb", MaxRedirectsOption(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	err = conn.Raw(func(driverConn any) error {
+		endpoint := driverConn.(*Conn).Endpoint()
+		if endpoint.Database != "monetdb" {
+			t.Errorf("Unexpected database: %s", endpoint.Database)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestConnectorMessagesIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	var received []mapi.Message
+	handler := mapi.MessageHandlerFunc(func(msg mapi.Message) {
+		received = append(received, msg)
+	})
+	connector, err := NewConnector("monetdb:monetdb@localhost:50000/monetdb", MessageHandlerOption(handler))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	received = nil
+	if _, err := conn.ExecContext(context.Background(), "SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+	err = conn.Raw(func(driverConn any) error {
+		messages := driverConn.(*Conn).Messages()
+		if len(messages) != len(received) {
+			t.Errorf("Unexpected messages %v, the handler received %v", messages, received)
+		}
+		for _, msg := range messages {
+			if msg.Query != "SELECT 1" {
+				t.Errorf("Unexpected statement of message: %v", msg)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,29 @@
+//go:build go1.21
+
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package monetdb
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/MonetDB/MonetDB-Go/v2/mapi"
+)
+
+// MessageLoggerOption logs the info and warning messages of the server with
+// the given logger. Messages that start with "warning" are logged at the warn
+// level, the others at the info level.
+func MessageLoggerOption(logger *slog.Logger) connectorOption {
+	return MessageHandlerOption(mapi.MessageHandlerFunc(func(msg mapi.Message) {
+		level := slog.LevelInfo
+		if strings.HasPrefix(strings.ToLower(msg.Text), "warning") {
+			level = slog.LevelWarn
+		}
+		logger.Log(context.Background(), level, msg.Text, "query", msg.Query)
+	}))
+}
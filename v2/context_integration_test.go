@@ -7,7 +7,9 @@ package monetdb
 import (
 	"database/sql"
 	"context"
+	"errors"
 	"testing"
+	"time"
 )
   
 func TestContextDBIntegration(t *testing.T) {
@@ -622,3 +624,45 @@ func TestContextRowIntegration(t *testing.T) {
 		}
 	})
 }
+
+func TestContextCancelIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	db, err := sql.Open("monetdb", "monetdb:monetdb@localhost:50000/monetdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	t.Run("Cancel long running query", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err := conn.ExecContext(ctx, "select count(*) from sys.generate_series(0, 100000) a, sys.generate_series(0, 100000) b")
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("Query was not interrupted, it took %s", elapsed)
+		}
+	})
+
+	t.Run("Use connection after cancel", func(t *testing.T) {
+		var n int
+		if err := conn.QueryRowContext(context.Background(), "select 1").Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		if n != 1 {
+			t.Errorf("Unexpected result: %d", n)
+		}
+	})
+}
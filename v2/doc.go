@@ -39,6 +39,42 @@ to the MonetDB server.
 
 If the port is not specified, then the default port 50000 will be used.
 
+To connect through a Unix domain socket, use the path of the socket instead
+of the hostname and port:
+
+    [username[:password]@]/tmp/.s.monetdb.50000/database
+
+When the socket file does not exist, the driver falls back to a TCP connection
+to localhost on the default port.
+
+To encrypt the connection with TLS, use the monetdbs URL scheme:
+
+    monetdbs://[username[:password]@]hostname[:port]/database
+
+The server certificate is verified against the trusted root certificates of
+the system. This works both when the server handles TLS itself and when a TLS
+terminating proxy is placed in front of it.
+
+The DSN can also be a URL as defined by the MonetDB URL specification, see
+https://www.monetdb.org/documentation/user-guide/client-interfaces/monetdb-urls/
+
+    monetdb[s]://[hostname[:port]]/[database][?param=value[&param=value]]
+
+Values must be percent-encoded. The host, port and database can only be part
+of the URL itself. The following parameters are supported: user, password,
+sock, sockdir, tls, cert, certhash, clientkey, clientcert, language,
+autocommit, replysize (or fetchsize), sizeheader, schema, timezone,
+connect_timeout, read_timeout, write_timeout, max_redirects, password_hash
+and stop_session. The timezone is the number of minutes east of UTC, or the
+name of a location like Europe/Amsterdam. The timeouts are given in seconds.
+When no hostname is given, the Unix domain socket in /tmp is tried before
+connecting to localhost.
+
+    monetdb://localhost:50000/demo?user=monetdb&password=monetdb&autocommit=false&replysize=1000
+
+Use ParseDSN to get the Config for a DSN and Config.FormatDSN to create the
+DSN for a Config.
+
 The second option is to use the Connector, which allows for additional configuration options:
 
 ``` go
@@ -61,6 +97,30 @@ The connector supports the following options:
 - ReplySize (default: 100): Maximum number of rows that will be returned in the resultset
 - Autocommit (default: enable): Commit each individual sql statement
 - Timezone (default: local timezone): Set the timezone of the database
+- Schema (default: none): Set the current schema of the session
+- Uploader (default: none): Provide the data for COPY INTO ... ON CLIENT statements
+- Downloader (default: none): Receive the data of COPY ... INTO ... ON CLIENT statements
+- Socket (default: none): Connect through a Unix domain socket, falling back to TCP when it is absent
+- TLSConfig (default: none): Encrypt the connection using the given tls.Config
+- CertHash (default: none): Encrypt the connection and pin the server certificate, e.g. "sha256:3a0f5c..."
+- ClientCert (default: none): Encrypt the connection and authenticate with a client certificate
+- ConnectTimeout (default: none): Maximum time to dial and log in
+- ReadTimeout (default: none): Maximum time to wait for each block of data from the server
+- WriteTimeout (default: none): Maximum time to send each block of data to the server
+- MaxRedirects (default: 10): Maximum number of times the login may be redirected
+- StopSession (default: disable): Stop cancelled statements with sys.stop when out-of-band interrupts are not available
+- PasswordHash (default: none): Log in with the hash of the password, e.g. "sha512:2b0a..." instead of the plain text password
+- MessageHandler (default: none): Receive the info and warning messages of the server
+- MessageLogger (default: none): Log the info and warning messages of the server with a slog.Logger (Go 1.21 and later)
+- Trace (default: none): Write a transcript of the protocol messages to an io.Writer, without the passwords
+- Replay (default: none): Serve a transcript that was written with Trace, instead of connecting to the server
+- DecimalAsFloat (default: disable): Return decimals as float64 instead of their exact value
+- NativeTypes (default: disable): Return UUID, INET, URL and JSON values as Go types instead of text
+
+The session settings (Sizeheader, ReplySize, Autocommit and Timezone) are sent
+along with the login when the server supports it, otherwise they are applied
+with separate commands right after the login. When a setting or the Schema
+cannot be applied, the connection fails with the error of the server.
 
 You can add the required options when creating the new connector:
 ``` go
@@ -68,5 +128,211 @@ You can add the required options when creating the new connector:
 		connector, err := monetdb.NewConnector("monetdb:monetdb@localhost:50000/monetdb", monetdb.SizeHeaderOption(true))
 	}
 ```
+
+## File transfers
+
+To load data that is stored on the client with COPY INTO ... FROM 'file' ON
+CLIENT, register a mapi.Uploader. It receives the file name from the query and
+returns an io.Reader with the content of the file:
+
+``` go
+	uploader := mapi.UploaderFunc(func(filename string, binary bool) (io.Reader, error) {
+		return os.Open(filepath.Join(dataDir, filepath.Base(filename)))
+	})
+	connector, err := monetdb.NewConnector(dsn, monetdb.UploaderOption(uploader))
+```
+
+To export the result of a query to the client with COPY SELECT ... INTO 'file'
+ON CLIENT, register a mapi.Downloader. It receives the file name and an
+io.Reader that returns the data while it arrives from the server, so large
+results can be streamed to a file or to object storage:
+
+``` go
+	downloader := mapi.DownloaderFunc(func(filename string, r io.Reader) error {
+		f, err := os.Create(filepath.Join(exportDir, filepath.Base(filename)))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(f, r)
+		return err
+	})
+	connector, err := monetdb.NewConnector(dsn, monetdb.DownloaderOption(downloader))
+```
+
+A different handler can be used for a single statement by passing the context
+returned by WithUploader or WithDownloader to ExecContext.
+
+## Errors
+
+The errors that the server reports for a statement are returned as a
+*mapi.Error, which holds the SQLSTATE code and the message. When the server
+sends several errors for one statement, they are linked with the Next field.
+The common classes of errors can be detected with errors.Is:
+
+``` go
+	_, err := db.Exec("INSERT INTO t VALUES (1)")
+	if errors.Is(err, mapi.ErrConstraintViolation) {
+		...
+	}
+	var merr *mapi.Error
+	if errors.As(err, &merr) {
+		log.Printf("SQLSTATE %s: %s", merr.Code, merr.Message)
+	}
+```
+
+The classes are ErrConstraintViolation, ErrSyntax, ErrMissingObject and
+ErrTransactionConflict. MonetDB uses SQLSTATE 42000 for more than syntax
+errors, like missing privileges, so ErrSyntax only matches when the message
+mentions a syntax error.
+
+## Transaction conflicts
+
+MonetDB uses optimistic concurrency control. When two transactions change the
+same data, the one that commits last is aborted, and the commit returns an
+error of the class mapi.ErrTransactionConflict. RunInTx runs a function in a
+transaction and runs it again after a conflict, with a growing pause between
+the attempts:
+
+``` go
+	err := monetdb.RunInTx(ctx, db, nil, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "UPDATE t SET total = total + 1")
+		return err
+	}, monetdb.MaxAttemptsOption(10), monetdb.BackoffOption(50*time.Millisecond, 5*time.Second))
+```
+
+## Server messages
+
+Besides the result of a statement, the server can send info and warning
+messages, for example about a deprecated feature. They are passed to the
+MessageHandler or MessageLogger of the connector while they arrive. The
+messages of the last statement on a connection are also available from the
+Messages function of the driver connection:
+
+``` go
+	err := conn.Raw(func(driverConn any) error {
+		for _, msg := range driverConn.(*monetdb.Conn).Messages() {
+			log.Printf("%s: %s", msg.Query, msg.Text)
+		}
+		return nil
+	})
+```
+
+## Protocol traces
+
+To report a problem with the communication between the driver and the server,
+record a transcript with the Trace option. The passwords are left out. The
+transcript can be replayed without the server, so the problem can be
+reproduced with the same statements:
+
+``` go
+	var transcript bytes.Buffer
+	connector, err := monetdb.NewConnector(dsn, monetdb.TraceOption(&transcript))
+	...
+	connector, err = monetdb.NewConnector(dsn, monetdb.ReplayOption(&transcript))
+```
+
+## Reading results
+
+The rows of a query are parsed while they arrive from the server, the whole
+result is never kept in memory. The server sends ReplySize rows at a time, the
+next block is fetched when the rows of the current block are read. Close the
+rows when they are not all needed, so the remaining rows of the block are
+discarded. When another statement is executed on the connection while the
+rows are still being read, for example in the same transaction, the rest of
+the block is read into memory first.
+
+## Data types
+
+Decimals are returned exactly, as text with all the digits of the scale of the
+column. They can be scanned into a string, a float64 or a mapi.Decimal, which
+converts to a *big.Rat with its Rat method. To scan into a *big.Rat directly,
+wrap it in a mapi.RatScanner. A mapi.Decimal can also be passed as an
+argument. With DecimalAsFloat the decimals are returned as float64.
+
+A HUGEINT is returned as int64 when it fits, otherwise as *big.Int. Scan it
+into a mapi.Int128 to accept both. Arguments can be a *big.Int, a mapi.Int128
+or an unsigned integer like uint64.
+
+A DATE is returned as mapi.Date and a TIME as mapi.Time, a TIMESTAMP as
+time.Time in UTC. A TIME WITH TIME ZONE is returned as time.Time on January 1,
+1970 with the offset of the value, a TIMESTAMP WITH TIME ZONE as time.Time in
+the Timezone of the connector. All of them keep the microseconds of the
+server. A time.Time argument is sent as a TIMESTAMP WITH TIME ZONE with its
+offset, the nanoseconds are truncated to microseconds.
+
+An INTERVAL SECOND or INTERVAL DAY is returned as time.Duration, an INTERVAL
+MONTH or INTERVAL YEAR as mapi.MonthInterval, the number of months. Both can
+be passed as arguments. The server stores the seconds with millisecond
+precision, so a time.Duration argument is rounded to milliseconds.
+
+UUID, INET, URL and JSON values are returned as text. With NativeTypes they
+are returned as mapi.UUID, netip.Prefix, *url.URL and json.RawMessage, an
+address without a netmask is a prefix with all its bits. A mapi.JSON
+unmarshals a JSON value into its V field when it is scanned, with or without
+NativeTypes:
+
+	var doc Document
+	err := db.QueryRow("select doc from documents").Scan(&mapi.JSON{V: &doc})
+
+These types, and also net.IP, netip.Addr and *net.IPNet, can be passed as
+arguments. A mapi.JSON argument is marshalled.
+
+A BLOB is returned as []byte. A []byte argument is sent as hexadecimal digits,
+so any byte can be stored. For a prepared statement this is only done for a
+BLOB placeholder, for other placeholders, like a VARCHAR, the bytes are sent
+as the text of a string. An io.Reader argument is read to the end and sent
+as a BLOB. Scan into a mapi.Blob to read the value with an io.Reader:
+
+	var b mapi.Blob
+	err := db.QueryRow("select data from files").Scan(&b)
+	_, err = io.Copy(f, b.Reader())
+
+Blobs are not streamed. An argument is buffered in full and sent as
+hexadecimal digits in the statement, and a value is decoded into memory with
+its row.
+
+## Prepared statements
+
+Prepare sends the statement to the server right away. The server describes the
+placeholders, so database/sql checks the number of arguments, and each
+argument is converted to the type of its placeholder. When the Go type does
+not match, the value is cast by the server, for example an int to a decimal
+or a string to a date. A nil argument becomes a NULL of the placeholder type.
+
+## Connection pool
+
+The connections of database/sql are reused by the next caller. Before that,
+a transaction that was left open is rolled back, and the autocommit mode,
+reply size, time zone and schema of the connector are restored when they
+were changed. A connection that had a network error is removed from the
+pool. Ping sends a statement to the server, so it reports a server that does
+not respond.
+
+Closing a prepared statement deallocates it on the server, and closing rows
+that were not read to the end releases the result set. These commands are
+sent together with the next statement on the connection, so they do not cost
+an extra round trip. Close statements and rows when they are no longer
+needed, long-lived connections otherwise accumulate them on the server.
+
+## Cancellation and timeouts
+
+When the context of a statement is cancelled, the query that is running on the
+server is interrupted and the method returns the error of the context. The
+connection remains usable. Out-of-band interrupts are not available on a TLS
+connection, on Windows and with older servers. With StopSession the server is
+then asked to stop the query with sys.stop on a separate connection, which
+requires that the user is allowed to stop its own sessions. Otherwise the
+connection is closed. The separate connection gets 10 seconds to stop the
+query. The context of QueryContext also applies while the rows are read, when
+it is cancelled the fetching of the next block of rows is interrupted in the
+same way.
+
+The deadline of the context also applies to the network connection, so the
+driver does not hang when the server stops responding. The server first gets
+some time to stop the statement after the deadline has passed. Timeouts of the
+network connection, including the ConnectTimeout, ReadTimeout and WriteTimeout,
+are reported as a *mapi.TimeoutError, which can be detected with errors.As.
+After a read or write timeout the connection is closed.
 */
 package monetdb
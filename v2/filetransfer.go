@@ -0,0 +1,45 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package monetdb
+
+import (
+	"context"
+
+	"github.com/MonetDB/MonetDB-Go/v2/mapi"
+)
+
+type uploaderKey struct{}
+type downloaderKey struct{}
+
+// WithUploader returns a context that makes the statement that is executed
+// with it use the given handler for COPY INTO ... ON CLIENT, instead of the
+// handler registered on the Connector.
+func WithUploader(ctx context.Context, uploader mapi.Uploader) context.Context {
+	return context.WithValue(ctx, uploaderKey{}, uploader)
+}
+
+// WithDownloader returns a context that makes the statement that is executed
+// with it use the given handler for COPY ... INTO ... ON CLIENT, instead of
+// the handler registered on the Connector.
+func WithDownloader(ctx context.Context, downloader mapi.Downloader) context.Context {
+	return context.WithValue(ctx, downloaderKey{}, downloader)
+}
+
+// setFileTransferHandlers prepares the connection for a statement that is
+// executed with the given context.
+func (c *Conn) setFileTransferHandlers(ctx context.Context) {
+	uploader := c.uploader
+	if u, ok := ctx.Value(uploaderKey{}).(mapi.Uploader); ok {
+		uploader = u
+	}
+	c.mapi.SetUploader(uploader)
+
+	downloader := c.downloader
+	if d, ok := ctx.Value(downloaderKey{}).(mapi.Downloader); ok {
+		downloader = d
+	}
+	c.mapi.SetDownloader(downloader)
+}
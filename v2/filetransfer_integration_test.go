@@ -0,0 +1,175 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+package monetdb
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/MonetDB/MonetDB-Go/v2/mapi"
+)
+
+func TestUploadIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	uploader := mapi.UploaderFunc(func(filename string, binary bool) (io.Reader, error) {
+		if filename != "data.csv" {
+			return nil, errors.New("file not found")
+		}
+		return strings.NewReader("1|name1\n2|name2\n3|name3\n"), nil
+	})
+	connector, err := NewConnector("monetdb:monetdb@localhost:50000/monetdb", UploaderOption(uploader))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	if pingErr := db.Ping(); pingErr != nil {
+		t.Fatal(pingErr)
+	}
+
+	t.Run("Exec create table", func(t *testing.T) {
+		_, err := db.Exec("create table test1 ( id int, name varchar(16))")
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Upload from client", func(t *testing.T) {
+		result, err := db.Exec("copy into test1 from 'data.csv' on client")
+		if err != nil {
+			t.Fatal(err)
+		}
+		nRows, err := result.RowsAffected()
+		if err != nil {
+			t.Error("Could not get number of rows from result")
+		}
+		if nRows != 3 {
+			t.Errorf("Unexpected number of rows %d", nRows)
+		}
+	})
+
+	t.Run("Upload with offset", func(t *testing.T) {
+		result, err := db.Exec("copy offset 3 into test1 from 'data.csv' on client")
+		if err != nil {
+			t.Fatal(err)
+		}
+		nRows, err := result.RowsAffected()
+		if err != nil {
+			t.Error("Could not get number of rows from result")
+		}
+		if nRows != 1 {
+			t.Errorf("Unexpected number of rows %d", nRows)
+		}
+	})
+
+	t.Run("Upload cancelled by server", func(t *testing.T) {
+		result, err := db.Exec("copy 1 records into test1 from 'data.csv' on client")
+		if err != nil {
+			t.Fatal(err)
+		}
+		nRows, err := result.RowsAffected()
+		if err != nil {
+			t.Error("Could not get number of rows from result")
+		}
+		if nRows != 1 {
+			t.Errorf("Unexpected number of rows %d", nRows)
+		}
+	})
+
+	t.Run("Upload rejected by handler", func(t *testing.T) {
+		_, err := db.Exec("copy into test1 from 'other.csv' on client")
+		if err == nil {
+			t.Error("Expected an error")
+		}
+	})
+
+	t.Run("Upload with handler from context", func(t *testing.T) {
+		other := mapi.UploaderFunc(func(filename string, binary bool) (io.Reader, error) {
+			return strings.NewReader("4|name4\n"), nil
+		})
+		ctx := WithUploader(context.Background(), other)
+		result, err := db.ExecContext(ctx, "copy into test1 from 'other.csv' on client")
+		if err != nil {
+			t.Fatal(err)
+		}
+		nRows, err := result.RowsAffected()
+		if err != nil {
+			t.Error("Could not get number of rows from result")
+		}
+		if nRows != 1 {
+			t.Errorf("Unexpected number of rows %d", nRows)
+		}
+	})
+
+	t.Run("Exec drop table", func(t *testing.T) {
+		_, err := db.Exec("drop table test1")
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestDownloadIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	var received bytes.Buffer
+	downloader := mapi.DownloaderFunc(func(filename string, r io.Reader) error {
+		if filename != "out.csv" {
+			return errors.New("file not allowed")
+		}
+		_, err := io.Copy(&received, r)
+		return err
+	})
+	connector, err := NewConnector("monetdb:monetdb@localhost:50000/monetdb", DownloaderOption(downloader))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	if pingErr := db.Ping(); pingErr != nil {
+		t.Fatal(pingErr)
+	}
+
+	t.Run("Download to client", func(t *testing.T) {
+		_, err := db.Exec("copy select value from sys.generate_series(1, 4) into 'out.csv' on client")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if received.String() != "1\n2\n3\n" {
+			t.Errorf("Unexpected download %q", received.String())
+		}
+	})
+
+	t.Run("Download rejected by handler", func(t *testing.T) {
+		_, err := db.Exec("copy select value from sys.generate_series(1, 4) into 'other.csv' on client")
+		if err == nil {
+			t.Error("Expected an error")
+		}
+	})
+
+	t.Run("Download with handler from context", func(t *testing.T) {
+		var other bytes.Buffer
+		ctx := WithDownloader(context.Background(), mapi.DownloaderFunc(func(filename string, r io.Reader) error {
+			_, err := io.Copy(&other, r)
+			return err
+		}))
+		_, err := db.ExecContext(ctx, "copy select 'name1' into 'other.csv' on client")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if other.String() != "\"name1\"\n" {
+			t.Errorf("Unexpected download %q", other.String())
+		}
+	})
+}
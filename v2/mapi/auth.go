@@ -0,0 +1,107 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapi
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+type hashAlgorithm struct {
+	name string
+	new  func() hash.Hash
+}
+
+// The algorithms that the server may ask for, from the strongest to the
+// weakest. The salted hash is computed with the first algorithm in this list
+// that the server offers. RIPEMD160 is left out, the server always offers a
+// SHA algorithm as well, and stores passwords with SHA512 by default.
+var hashAlgorithms = []hashAlgorithm{
+	{"SHA3-512", sha3.New512},
+	{"SHA512", sha512.New},
+	{"SHA3-384", sha3.New384},
+	{"SHA384", sha512.New384},
+	{"SHA3-256", sha3.New256},
+	{"SHA256", sha256.New},
+	{"SHA3-224", sha3.New224},
+	{"SHA224", sha256.New224},
+	{"SHA1", sha1.New},
+	{"MD5", md5.New},
+}
+
+func findHashAlgorithm(name string) (hashAlgorithm, bool) {
+	for _, a := range hashAlgorithms {
+		if strings.EqualFold(a.name, name) {
+			return a, true
+		}
+	}
+	return hashAlgorithm{}, false
+}
+
+// passwordDigest returns the hex encoded hash of the password, using the
+// algorithm the server uses to store passwords. When a PasswordHash is
+// configured it is used instead of the password, so the plain text password
+// does not have to be known.
+func (c *mapiConn) passwordDigest(algo string) (string, error) {
+	a, ok := findHashAlgorithm(algo)
+	if !ok {
+		return "", fmt.Errorf("mapi: unsupported algorithm: %s", algo)
+	}
+
+	if c.PasswordHash != "" {
+		name, digest, found := Cut(c.PasswordHash, ":")
+		if !found {
+			return "", fmt.Errorf("mapi: invalid password hash, expected <algorithm>:<hexdigits>")
+		}
+		if !strings.EqualFold(name, a.name) {
+			return "", fmt.Errorf("mapi: password hash uses %s, but the server requires %s", name, a.name)
+		}
+		if _, err := hex.DecodeString(digest); err != nil || len(digest) != 2*a.new().Size() {
+			return "", fmt.Errorf("mapi: invalid %s password hash", a.name)
+		}
+		return strings.ToLower(digest), nil
+	}
+
+	h := a.new()
+	io.WriteString(h, c.Password)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// saltedHash combines the password digest with the salt of the challenge,
+// using the strongest of the algorithms the server offers
+func saltedHash(offered string, digest string, salt string) (string, error) {
+	names := "," + strings.ToUpper(offered) + ","
+	for _, a := range hashAlgorithms {
+		if !strings.Contains(names, ","+a.name+",") {
+			continue
+		}
+		h := a.new()
+		io.WriteString(h, digest)
+		io.WriteString(h, salt)
+		return fmt.Sprintf("{%s}%x", a.name, h.Sum(nil)), nil
+	}
+	return "", fmt.Errorf("mapi: unsupported hash algorithm required for login %s", offered)
+}
+
+// HashPassword returns the value for Config.PasswordHash, for a server that
+// stores passwords with the given algorithm, normally SHA512
+func HashPassword(algo string, password string) (string, error) {
+	a, ok := findHashAlgorithm(algo)
+	if !ok {
+		return "", fmt.Errorf("mapi: unsupported algorithm: %s", algo)
+	}
+	h := a.new()
+	io.WriteString(h, password)
+	return fmt.Sprintf("%s:%x", strings.ToLower(a.name), h.Sum(nil)), nil
+}
@@ -0,0 +1,100 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapi
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSaltedHash(t *testing.T) {
+	digest, err := (&mapiConn{Config: Config{Password: "monetdb"}}).passwordDigest("SHA512")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tcs := []struct {
+		offered  string
+		expected string
+	}{
+		{"RIPEMD160,SHA512,SHA384,SHA256,SHA224,SHA1", "{SHA512}746ded2fe4dee042511f6e7c73d718dfdc96016a4b24444cc672043ec0263e9af03823b17b49ac771222d60f5d635034925453d02b40d81fe8951ab8a34c2688"},
+		{"SHA512,SHA3-512", "{SHA3-512}2653b15669d305a8e21bd129ee04ce4b2de5119c2a2db76829e6ec1dc407636c6d75568f2405e64bb69b9e0e8575fb2475b137ab08ef3a9d0e21a4d177463663"},
+	}
+	for _, tc := range tcs {
+		pwhash, err := saltedHash(tc.offered, digest, "salt")
+		if err != nil {
+			t.Errorf("Error hashing with %s: %v", tc.offered, err)
+		} else if pwhash != tc.expected {
+			t.Errorf("Unexpected hash for %s: %s", tc.offered, pwhash)
+		}
+	}
+
+	for offered, prefix := range map[string]string{
+		"SHA1,MD5":        "{SHA1}",
+		"MD5,RIPEMD160":   "{MD5}",
+		"SHA224,SHA256":   "{SHA256}",
+		"SHA384,SHA3-256": "{SHA384}",
+	} {
+		pwhash, err := saltedHash(offered, digest, "salt")
+		if err != nil || !strings.HasPrefix(pwhash, prefix) {
+			t.Errorf("Unexpected hash for %s: %s %v", offered, pwhash, err)
+		}
+	}
+
+	if _, err := saltedHash("PLAIN,CRYPT", digest, "salt"); err == nil {
+		t.Error("Expected an error for unsupported algorithms")
+	}
+}
+
+func TestChallengeResponse(t *testing.T) {
+	t.Run("Verify primary algorithms", func(t *testing.T) {
+		for _, algo := range []string{"SHA512", "SHA384", "SHA256", "SHA224", "SHA3-512"} {
+			c := NewMapiWithConfig(DefaultConfig())
+			c.Username = "monetdb"
+			c.Password = "monetdb"
+			r, err := c.challengeResponse([]byte("salt:mserver:9:SHA256,SHA1:LIT:" + algo + ":"))
+			if err != nil {
+				t.Errorf("Error responding to %s: %v", algo, err)
+			} else if !strings.HasPrefix(r, "BIG:monetdb:{SHA256}") {
+				t.Errorf("Unexpected response for %s: %s", algo, r)
+			}
+		}
+
+		c := NewMapiWithConfig(DefaultConfig())
+		if _, err := c.challengeResponse([]byte("salt:mserver:9:SHA1:LIT:CRC32:")); err == nil {
+			t.Error("Expected an error for an unsupported algorithm")
+		}
+	})
+
+	t.Run("Verify password hash", func(t *testing.T) {
+		challenge := []byte("salt:mserver:9:SHA512,SHA1:LIT:SHA512:")
+		c := NewMapiWithConfig(DefaultConfig())
+		c.Password = "monetdb"
+		expected, err := c.challengeResponse(challenge)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c = NewMapiWithConfig(DefaultConfig())
+		c.PasswordHash, err = HashPassword("SHA512", "monetdb")
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := c.challengeResponse(challenge)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r != expected {
+			t.Errorf("Unexpected response: %s, expected: %s", r, expected)
+		}
+
+		for _, hash := range []string{"sha256:" + strings.Repeat("ab", 32), "sha512:xyz", "abcdef"} {
+			c.PasswordHash = hash
+			if _, err := c.challengeResponse(challenge); err == nil {
+				t.Errorf("Expected an error for password hash %s", hash)
+			}
+		}
+	})
+}
@@ -0,0 +1,104 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapi
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Blob holds a value of a BLOB column, which can be read with an io.Reader,
+// like
+//
+//	var b mapi.Blob
+//	err := row.Scan(&b)
+//	_, err = io.Copy(f, b.Reader())
+//
+// The value is not streamed, the whole blob is decoded into memory with its
+// row. An io.Reader can be passed as an argument for a BLOB, it is read to
+// the end and sent in the text of the statement, so it is buffered in full.
+type Blob struct {
+	data []byte
+}
+
+// NewBlob returns a blob with a copy of data
+func NewBlob(data []byte) Blob {
+	return Blob{data: append([]byte{}, data...)}
+}
+
+// Len returns the number of bytes of the blob
+func (b Blob) Len() int {
+	return len(b.data)
+}
+
+// Bytes returns the bytes of the blob
+func (b Blob) Bytes() []byte {
+	return b.data
+}
+
+// Reader returns a reader of the bytes of the blob
+func (b Blob) Reader() io.Reader {
+	return bytes.NewReader(b.data)
+}
+
+// Scan implements the sql.Scanner interface. The bytes are copied, because
+// the bytes of the driver are only valid until the next row.
+func (b *Blob) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case Blob:
+		*b = v
+	case []byte:
+		*b = NewBlob(v)
+	case nil:
+		return fmt.Errorf("mapi: cannot scan NULL into a Blob")
+	default:
+		return fmt.Errorf("mapi: cannot scan %T into a Blob", src)
+	}
+	return nil
+}
+
+// Value implements the driver.Valuer interface
+func (b Blob) Value() (driver.Value, error) {
+	return b.data, nil
+}
+
+// toByteArray decodes a blob, which the server sends as hexadecimal digits
+func toByteArray(v string) (Value, error) {
+	digits := strings.Trim(v, "\"'")
+	b, err := hex.DecodeString(digits)
+	if err != nil {
+		return nil, fmt.Errorf("mapi: invalid blob: %v", err)
+	}
+	return b, nil
+}
+
+// toBlobString renders bytes, a Blob or the bytes of an io.Reader as
+// hexadecimal digits cast to a blob, so any byte survives the statement. The
+// literal is twice the size of the blob.
+func toBlobString(v Value) (string, error) {
+	var sb strings.Builder
+	sb.WriteString("CAST('")
+	switch val := v.(type) {
+	case []byte:
+		if val == nil {
+			return "NULL", nil
+		}
+		sb.WriteString(hex.EncodeToString(val))
+	case Blob:
+		sb.WriteString(hex.EncodeToString(val.data))
+	case io.Reader:
+		if _, err := io.Copy(hex.NewEncoder(&sb), val); err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("mapi: unsupported type")
+	}
+	sb.WriteString("' AS blob)")
+	return sb.String(), nil
+}
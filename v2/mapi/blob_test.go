@@ -0,0 +1,71 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapi
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestBlob(t *testing.T) {
+	data := []byte{0, 1, 0xfe, 0xff, '\'', '\\', 0xc3}
+
+	t.Run("Verify bytes round trip", func(t *testing.T) {
+		literal, err := ConvertToMonet(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if literal != "CAST('0001feff275cc3' AS blob)" {
+			t.Fatalf("Unexpected literal: %s", literal)
+		}
+		v, err := convertToGo("0001FEFF275CC3", MDB_BLOB)
+		if err != nil || !bytes.Equal(v.([]byte), data) {
+			t.Errorf("Unexpected bytes: %v, %v", v, err)
+		}
+	})
+
+	t.Run("Verify empty and invalid blobs", func(t *testing.T) {
+		v, err := convertToGo("", MDB_BLOB)
+		if err != nil || len(v.([]byte)) != 0 {
+			t.Errorf("Unexpected bytes: %v, %v", v, err)
+		}
+		if _, err := convertToGo("0G", MDB_BLOB); err == nil {
+			t.Errorf("Expected error for invalid blob")
+		}
+		literal, _ := ConvertToMonet([]byte(nil))
+		if literal != "NULL" {
+			t.Errorf("Unexpected literal: %s", literal)
+		}
+	})
+
+	t.Run("Verify reader arguments", func(t *testing.T) {
+		literal, err := ConvertToMonet(bytes.NewReader(data))
+		if err != nil || literal != "CAST('0001feff275cc3' AS blob)" {
+			t.Errorf("Unexpected literal: %s, %v", literal, err)
+		}
+		literal, _ = ConvertParameter(strings.NewReader("a"), Parameter{ColumnType: MDB_BLOB})
+		if literal != "CAST('61' AS blob)" {
+			t.Errorf("Unexpected literal: %s", literal)
+		}
+	})
+
+	t.Run("Verify blob is read with a reader", func(t *testing.T) {
+		var b Blob
+		src := append([]byte{}, data...)
+		if err := b.Scan(src); err != nil {
+			t.Fatal(err)
+		}
+		src[0] = 42
+		read, err := io.ReadAll(b.Reader())
+		if err != nil || !bytes.Equal(read, data) || b.Len() != len(data) {
+			t.Errorf("Unexpected bytes: %v, %v", read, err)
+		}
+		if err := b.Scan(nil); err == nil {
+			t.Errorf("Expected error for NULL")
+		}
+	})
+}
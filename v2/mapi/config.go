@@ -9,17 +9,92 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
-type config struct {
+// Config holds the parameters of a connection to the MonetDB server. It is
+// filled by parsing a DSN, either in the form
+//
+//	[username[:password]@]hostname[:port]/database
+//
+// or as a URL following the MonetDB URL specification, see
+// https://www.monetdb.org/documentation/user-guide/client-interfaces/monetdb-urls/
+//
+//	monetdb[s]://[hostname[:port]]/[database][?param=value[&param=value]]
+type Config struct {
 	Username string
 	Password string
+
+	// PasswordHash replaces the Password, in the form <algorithm>:<hexdigits>,
+	// for example sha512:2b0a... It must use the algorithm the server uses
+	// to store passwords. HashPassword creates the value.
+	PasswordHash string
+
 	Hostname string
 	Database string
 	Port     int
+	Socket   string
+	Language string
+
+	// TLS encrypts the connection. The certificate and key settings are
+	// file names, they are only used when TLS is enabled.
+	TLS            bool
+	CertFile       string
+	CertHash       string
+	ClientKeyFile  string
+	ClientCertFile string
+
+	// Session settings, they are sent in the login response when the
+	// server supports it, otherwise they are applied after the login
+	AutoCommit bool
+	ReplySize  int
+	Sizeheader bool
+	Timezone   *time.Location
+	Schema     string
+
+	// Timeouts, zero means no timeout. The connect timeout covers dialing
+	// and the login, the others apply to every read and write of a block.
+	ConnectTimeout time.Duration
+	ReadTimeout    time.Duration
+	WriteTimeout   time.Duration
+
+	// MaxRedirects is the number of times the server may redirect the
+	// login to another server, or restart it
+	MaxRedirects int
+
+	// StopSession lets a running statement be stopped with sys.stop from a
+	// separate connection, when the connection cannot send an out-of-band
+	// interrupt. The id of the session is then asked together with the
+	// first command.
+	StopSession bool
+}
+
+// DefaultConfig returns the configuration that is used for every setting
+// that is not specified in the DSN.
+func DefaultConfig() Config {
+	return Config{
+		Hostname:   "localhost",
+		Port:       50000,
+		Language:   "sql",
+		AutoCommit: true,
+		ReplySize:  MAPI_ARRAY_SIZE,
+		Sizeheader: true,
+		Timezone:   time.Local,
+
+		MaxRedirects: mapi_MAX_REDIRECTS,
+	}
 }
 
-func parseDSN(name string) (config, error) {
+// ParseDSN parses a data source name in either of the supported formats.
+func ParseDSN(name string) (Config, error) {
+	return parseDSN(name)
+}
+
+func parseDSN(name string) (Config, error) {
+	if strings.HasPrefix(name, "monetdb://") || strings.HasPrefix(name, "monetdbs://") {
+		return parseURL(name)
+	}
+
 	ipv6_re := regexp.MustCompile(`^((?P<username>[^:]+?)(:(?P<password>[^@]+?))?@)?\[(?P<hostname>(([0-9a-fA-F]{1,4}:){7,7}[0-9a-fA-F]{1,4}|([0-9a-fA-F]{1,4}:){1,7}:|([0-9a-fA-F]{1,4}:){1,6}:[0-9a-fA-F]{1,4}|([0-9a-fA-F]{1,4}:){1,5}(:[0-9a-fA-F]{1,4}){1,2}|([0-9a-fA-F]{1,4}:){1,4}(:[0-9a-fA-F]{1,4}){1,3}|([0-9a-fA-F]{1,4}:){1,3}(:[0-9a-fA-F]{1,4}){1,4}|([0-9a-fA-F]{1,4}:){1,2}(:[0-9a-fA-F]{1,4}){1,5}|[0-9a-fA-F]{1,4}:((:[0-9a-fA-F]{1,4}){1,6})|:((:[0-9a-fA-F]{1,4}){1,7}|:)|fe80:(:[0-9a-fA-F]{0,4}){0,4}%[0-9a-zA-Z]{1,}|::(ffff(:0{1,4}){0,1}:){0,1}((25[0-5]|(2[0-4]|1{0,1}[0-9]){0,1}[0-9])\.){3,3}(25[0-5]|(2[0-4]|1{0,1}[0-9]){0,1}[0-9])|([0-9a-fA-F]{1,4}:){1,4}:((25[0-5]|(2[0-4]|1{0,1}[0-9]){0,1}[0-9])\.){3,3}(25[0-5]|(2[0-4]|1{0,1}[0-9]){0,1}[0-9]))+?)\](:(?P<port>\d+?))?\/(?P<database>.+?)$`)
 
 	if ipv6_re.MatchString(name) {
@@ -28,10 +103,7 @@ func parseDSN(name string) (config, error) {
 		return getConfig(m, n, true), nil
 	}
 
-	c := config{
-		Hostname: "localhost",
-		Port:     50000,
-	}
+	c := DefaultConfig()
 
 	reversed := reverse(name)
 
@@ -40,19 +112,19 @@ func parseDSN(name string) (config, error) {
 	configWithHost, err := parseHost(reverse(host), c)
 
 	if err != nil {
-		return config{}, fmt.Errorf("mapi: invalid DSN")
+		return Config{}, fmt.Errorf("mapi: invalid DSN")
 	}
 
 	newConfig, err := parseCreds(reverse(creds), configWithHost)
 
 	if err != nil {
-		return config{}, fmt.Errorf("mapi: invalid DSN")
+		return Config{}, fmt.Errorf("mapi: invalid DSN")
 	}
 
 	return newConfig, nil
 }
 
-func parseCreds(creds string, c config) (config, error) {
+func parseCreds(creds string, c Config) (Config, error) {
 	username, password, found := Cut(creds, ":")
 
 	c.Username = username
@@ -69,7 +141,11 @@ func parseCreds(creds string, c config) (config, error) {
 	return c, nil
 }
 
-func parseHost(host string, c config) (config, error) {
+func parseHost(host string, c Config) (Config, error) {
+	if strings.HasPrefix(host, "/") {
+		return parseSocket(host, c)
+	}
+
 	host, dbName, found := Cut(host, "/")
 
 	if !found {
@@ -101,11 +177,25 @@ func parseHost(host string, c config) (config, error) {
 	return c, nil
 }
 
-func getConfig(m []string, n []string, ipv6 bool) config {
-	c := config{
-		Hostname: "localhost",
-		Port:     50000,
+// parseSocket handles a DSN where the host is the path of a Unix domain socket,
+// for example /tmp/.s.monetdb.50000/database. The last path element is the
+// name of the database.
+func parseSocket(path string, c Config) (Config, error) {
+	i := strings.LastIndex(path, "/")
+	socket, dbName := path[:i], path[i+1:]
+
+	if socket == "" || dbName == "" {
+		return c, fmt.Errorf("mapi: invalid DSN")
 	}
+
+	c.Socket = socket
+	c.Database = dbName
+
+	return c, nil
+}
+
+func getConfig(m []string, n []string, ipv6 bool) Config {
+	c := DefaultConfig()
 	for i, v := range m {
 		if n[i] == "username" {
 			c.Username = v
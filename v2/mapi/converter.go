@@ -5,7 +5,9 @@
 package mapi
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"reflect"
 	"strconv"
 	"strings"
@@ -22,7 +24,7 @@ const (
 	MDB_SMALLINT  = "smallint" // 16 bit integer
 	MDB_INT       = "int"      // 32 bit integer
 	MDB_BIGINT    = "bigint"   // 64 bit integer
-	MDB_HUGEINT   = "hugeint"  // 64 bit integer
+	MDB_HUGEINT   = "hugeint"  // 128 bit integer
 	MDB_SERIAL    = "serial"   // special 64 bit integer sequence generator
 	MDB_REAL      = "real"     // 32 bit floating point
 	MDB_DOUBLE    = "double"   // 64 bit floating point
@@ -36,6 +38,7 @@ const (
 
 	MDB_MONTH_INTERVAL = "month_interval"
 	MDB_SEC_INTERVAL   = "sec_interval"
+	MDB_DAY_INTERVAL   = "day_interval"
 	MDB_WRD            = "wrd"
 	MDB_TINYINT        = "tinyint"
 
@@ -44,6 +47,7 @@ const (
 	MDB_MEDIUMINT   = "mediumint"
 	MDB_LONGINT     = "longint"
 	MDB_TIMESTAMPTZ = "timestamptz"
+	MDB_TIMETZ      = "timetz"
 
 	// full names and aliases, spaces are replaced with underscores
 	// We don't need to define the aliases, because they are never used. The
@@ -125,10 +129,6 @@ func unquote(s string) (string, error) {
 	return string(buf), nil
 }
 
-func toByteArray(v string) (Value, error) {
-	return []byte(v[1 : len(v)-1]), nil
-}
-
 func toDouble(v string) (Value, error) {
 	return strconv.ParseFloat(v, 64)
 }
@@ -212,13 +212,30 @@ func toTime(v string) (Value, error) {
 	if err != nil {
 		return nil, err
 	}
+	return GetTime(t), nil
+}
+
+// toTimeTz converts a time with its offset, like 10:20:30.123456+01:00, to
+// a time.Time on January 1, 1970
+func toTimeTz(v string) (Value, error) {
+	t, err := time.Parse("15:04:05-07:00", v)
+	if err != nil {
+		return nil, err
+	}
 	hour, min, sec := t.Clock()
-	return Time{hour, min, sec}, nil
+	return time.Date(1970, time.January, 1, hour, min, sec, t.Nanosecond(), t.Location()), nil
 }
+
 func toTimestamp(v string) (Value, error) {
 	return parseTime(v)
 }
+
+// toTimestampTz converts a timestamp with its offset, like
+// 2001-01-02 10:20:30.123456+01:00
 func toTimestampTz(v string) (Value, error) {
+	if t, err := time.Parse("2006-01-02 15:04:05-07:00", v); err == nil {
+		return t, nil
+	}
 	return parseTime(v)
 }
 
@@ -227,13 +244,13 @@ var toGoMappers = map[string]toGoConverter{
 	MDB_VARCHAR:        strip,
 	MDB_CLOB:           strip,
 	MDB_BLOB:           toByteArray,
-	MDB_DECIMAL:        toDouble,
+	MDB_DECIMAL:        parseDecimal,
 	MDB_NULL:           toNil,
 	MDB_SMALLINT:       toInt16,
 	MDB_INT:            toInt32,
 	MDB_WRD:            toInt32,
 	MDB_BIGINT:         toInt64,
-	MDB_HUGEINT:        toInt64,
+	MDB_HUGEINT:        toHugeint,
 	MDB_SERIAL:         toInt64,
 	MDB_REAL:           toFloat,
 	MDB_DOUBLE:         toDouble,
@@ -242,9 +259,11 @@ var toGoMappers = map[string]toGoConverter{
 	MDB_TIME:           toTime,
 	MDB_TIMESTAMP:      toTimestamp,
 	MDB_TIMESTAMPTZ:    toTimestampTz,
+	MDB_TIMETZ:         toTimeTz,
 	MDB_INTERVAL:       strip,
-	MDB_MONTH_INTERVAL: strip,
-	MDB_SEC_INTERVAL:   toDouble,
+	MDB_MONTH_INTERVAL: toMonthInterval,
+	MDB_SEC_INTERVAL:   toDuration,
+	MDB_DAY_INTERVAL:   toDuration,
 	MDB_TINYINT:        toInt8,
 	MDB_SHORTINT:       toInt16,
 	MDB_MEDIUMINT:      toInt32,
@@ -271,21 +290,28 @@ func toNull(v Value) (string, error) {
 	return "NULL", nil
 }
 
-func toByteString(v Value) (string, error) {
+// toTimestampString renders a time.Time with its offset, the server keeps
+// microseconds, so the fraction is truncated. An offset with seconds, like
+// the local mean time of old dates, cannot be written, such a time is
+// rendered in UTC.
+func toTimestampString(v Value) (string, error) {
 	switch val := v.(type) {
-	case []uint8:
-		return toQuotedString(string(val))
+	case time.Time:
+		if _, offset := val.Zone(); offset%60 != 0 {
+			val = val.UTC()
+		}
+		return fmt.Sprintf("TIMESTAMP WITH TIME ZONE '%s'", val.Format("2006-01-02 15:04:05.999999-07:00")), nil
 	default:
-		return "", fmt.Errorf("unsupported type")
+		return "", fmt.Errorf("mapi: unsupported type")
 	}
 }
 
 func toDateTimeString(v Value) (string, error) {
 	switch val := v.(type) {
 	case Time:
-		return toQuotedString(fmt.Sprintf("%02d:%02d:%02d", val.Hour, val.Min, val.Sec))
+		return toQuotedString(val.String())
 	case Date:
-		return toQuotedString(fmt.Sprintf("%04d-%02d-%02d", val.Year, val.Month, val.Day))
+		return toQuotedString(val.String())
 	default:
 		return "", fmt.Errorf("mapi: unsupported type")
 	}
@@ -297,6 +323,11 @@ var toMonetMappers = map[string]toMonetConverter{
 	"int16":        toString,
 	"int32":        toString,
 	"int64":        toString,
+	"uint":         toString,
+	"uint8":        toString,
+	"uint16":       toString,
+	"uint32":       toString,
+	"uint64":       toString,
 	"float":        toString,
 	"float32":      toString,
 	"float64":      toString,
@@ -304,10 +335,25 @@ var toMonetMappers = map[string]toMonetConverter{
 	"string":       toQuotedString,
 	"nil":          toNull,
 	"null":         toNull,
-	"[]uint8":      toByteString,
-	"time.Time":    toQuotedString,
+	"[]uint8":      toBlobString,
+	"time.Time":    toTimestampString,
 	"mapi.Time": toDateTimeString,
 	"mapi.Date": toDateTimeString,
+	"mapi.Decimal": toDecimalString,
+	"mapi.Int128": toBigIntString,
+	"*big.Int": toBigIntString,
+	"time.Duration": toIntervalString,
+	"mapi.MonthInterval": toIntervalString,
+	"mapi.Blob": toBlobString,
+	"mapi.UUID": toNativeString,
+	"mapi.JSON": toNativeString,
+	// json.RawMessage is an alias in some versions of encoding/json
+	reflect.TypeOf(json.RawMessage{}).String(): toNativeString,
+	"netip.Prefix": toNativeString,
+	"netip.Addr": toNativeString,
+	"net.IP": toNativeString,
+	"*net.IPNet": toNativeString,
+	"*url.URL": toNativeString,
 }
 
 func convertToGo(value, dataType string) (Value, error) {
@@ -332,5 +378,8 @@ func ConvertToMonet(value Value) (string, error) {
 	if mapper, ok := toMonetMappers[n]; ok {
 		return mapper(value)
 	}
+	if _, ok := value.(io.Reader); ok {
+		return toBlobString(value)
+	}
 	return "", fmt.Errorf("mapi: type not supported: %v", t)
 }
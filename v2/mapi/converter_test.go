@@ -6,6 +6,7 @@ package mapi
 
 import (
 	"bytes"
+	"math/big"
 	"testing"
 	"time"
 )
@@ -31,11 +32,20 @@ func TestConvertToMonet(t *testing.T) {
 		{true, "true"},
 		{false, "false"},
 		{nil, "NULL"},
-		{[]byte{1, 2, 3}, "'" + string([]byte{1, 2, 3}) + "'"},
-		{Time{10, 20, 30}, "'10:20:30'"},
+		{[]byte{1, 2, 3}, "CAST('010203' AS blob)"},
+		{Time{10, 20, 30, 0}, "'10:20:30'"},
 		{Date{2001, time.January, 2}, "'2001-01-02'"},
+		{Decimal{big.NewInt(-1234), 3}, "-1.234"},
+		{uint64(18446744073709551615), "18446744073709551615"},
+		{new(big.Int).Lsh(big.NewInt(1), 100), "1267650600228229401496703205376"},
+		{Int128{Hi: 1, Lo: 0}, "18446744073709551616"},
 		{time.Date(2001, time.January, 2, 10, 20, 30, 0, time.FixedZone("CET", 3600)),
-			"'2001-01-02 10:20:30 +0100 CET'"},
+			"TIMESTAMP WITH TIME ZONE '2001-01-02 10:20:30+01:00'"},
+		{time.Date(2001, time.January, 2, 10, 20, 30, 123456789, time.UTC),
+			"TIMESTAMP WITH TIME ZONE '2001-01-02 10:20:30.123456+00:00'"},
+		{time.Date(1900, time.January, 2, 0, 0, 0, 0, time.FixedZone("LMT", 1172)),
+			"TIMESTAMP WITH TIME ZONE '1900-01-01 23:40:28+00:00'"},
+		{Time{10, 20, 30, 500000000}, "'10:20:30.500000'"},
 	}
 
 	for _, c := range tcs {
@@ -67,18 +77,20 @@ func TestConvertToGo(t *testing.T) {
 		{"3.2", "float", float32(3.2)},
 		{"3.2", "real", float32(3.2)},
 		{"6.4", "double", float64(6.4)},
-		{"6.4", "decimal", float64(6.4)},
+		{"6.4", "decimal", Decimal{big.NewInt(64), 1}},
 		{"true", "boolean", true},
 		{"false", "boolean", false},
-		{"10:20:30", "time", Time{10, 20, 30}},
+		{"10:20:30", "time", Time{10, 20, 30, 0}},
+		{"10:20:30.000123", "time", Time{10, 20, 30, 123000}},
 		{"2001-01-02", "date", Date{2001, time.January, 2}},
+		{"2001-01-02 10:20:30.123456", "timestamp", time.Date(2001, time.January, 2, 10, 20, 30, 123456000, time.UTC)},
 		{"'string'", "char", "string"},
 		{"'string'", "varchar", "string"},
 		{"'quoted \"string\"'", "char", "quoted \"string\""},
 		{"'quoted \\'string\\''", "char", "quoted 'string'"},
 		{"'quoted \\\\\\'string\\\\\\''", "char", "quoted \\'string\\'"},
 		{"'back\\\\slashed'", "char", "back\\slashed"},
-		{"'ABC'", "blob", []uint8{0x41, 0x42, 0x43}},
+		{"414243", "blob", []uint8{0x41, 0x42, 0x43}},
 	}
 
 	for _, c := range tcs {
@@ -90,6 +102,8 @@ func TestConvertToGo(t *testing.T) {
 			switch val := v.(type) {
 			case []byte:
 				ok = compareByteArray(t, val, c.e)
+			case Decimal:
+				ok = val.String() == c.e.(Decimal).String()
 			default:
 				ok = v == c.e
 			}
@@ -0,0 +1,164 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapi
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Decimal is an exact value of a DECIMAL column. It holds the digits without
+// the decimal point and the number of digits after the point, so 12.340 is
+// 12340 with scale 3. The zero value is 0.
+type Decimal struct {
+	unscaled *big.Int
+	scale    int
+}
+
+// NewDecimal returns the decimal unscaled * 10^-scale
+func NewDecimal(unscaled *big.Int, scale int) Decimal {
+	return Decimal{unscaled: new(big.Int).Set(unscaled), scale: scale}
+}
+
+// ParseDecimal parses a decimal like -12.340. The scale is the number of
+// digits after the point.
+func ParseDecimal(s string) (Decimal, error) {
+	digits := strings.TrimSpace(s)
+	scale := 0
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		scale = len(digits) - i - 1
+		digits = digits[:i] + digits[i+1:]
+	}
+	unscaled, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return Decimal{}, fmt.Errorf("mapi: invalid decimal: %q", s)
+	}
+	return Decimal{unscaled: unscaled, scale: scale}, nil
+}
+
+// Unscaled returns the digits of the decimal without the point
+func (d Decimal) Unscaled() *big.Int {
+	if d.unscaled == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(d.unscaled)
+}
+
+// Scale returns the number of digits after the point
+func (d Decimal) Scale() int {
+	return d.scale
+}
+
+// String returns the decimal with all the digits of its scale, like 12.340
+func (d Decimal) String() string {
+	digits := d.Unscaled().String()
+	if d.scale <= 0 {
+		return digits
+	}
+	sign := ""
+	if strings.HasPrefix(digits, "-") {
+		sign, digits = "-", digits[1:]
+	}
+	if len(digits) <= d.scale {
+		digits = strings.Repeat("0", d.scale-len(digits)+1) + digits
+	}
+	point := len(digits) - d.scale
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+// Rat returns the exact value of the decimal
+func (d Decimal) Rat() *big.Rat {
+	r := new(big.Rat).SetInt(d.Unscaled())
+	if d.scale > 0 {
+		scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(d.scale)), nil)
+		r.Quo(r, new(big.Rat).SetInt(scale))
+	}
+	return r
+}
+
+// Float64 returns the nearest float64 value of the decimal
+func (d Decimal) Float64() float64 {
+	f, _ := d.Rat().Float64()
+	return f
+}
+
+// Scan implements the sql.Scanner interface. It accepts the decimals of
+// the driver, strings, integers and floats.
+func (d *Decimal) Scan(src interface{}) error {
+	var err error
+	switch v := src.(type) {
+	case Decimal:
+		*d = v
+	case string:
+		*d, err = ParseDecimal(v)
+	case []byte:
+		*d, err = ParseDecimal(string(v))
+	case int64:
+		*d = Decimal{unscaled: big.NewInt(v)}
+	case float64:
+		*d, err = ParseDecimal(strconv.FormatFloat(v, 'f', -1, 64))
+	case nil:
+		err = fmt.Errorf("mapi: cannot scan NULL into a Decimal")
+	default:
+		err = fmt.Errorf("mapi: cannot scan %T into a Decimal", src)
+	}
+	return err
+}
+
+// Value implements the driver.Valuer interface
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+// RatScanner scans a decimal into R, because database/sql cannot scan into
+// a *big.Rat itself, like
+//
+//	r := new(big.Rat)
+//	err := row.Scan(mapi.RatScanner{R: r})
+type RatScanner struct {
+	R *big.Rat
+}
+
+// Scan implements the sql.Scanner interface. It accepts the values that a
+// Decimal accepts.
+func (s RatScanner) Scan(src interface{}) error {
+	var d Decimal
+	if err := d.Scan(src); err != nil {
+		return err
+	}
+	s.R.Set(d.Rat())
+	return nil
+}
+
+// toDecimal converts a decimal of a result set. The server sends all the
+// digits of the scale of the column, when it does not the value is scaled.
+func toDecimal(v string, scale int) (Value, error) {
+	d, err := ParseDecimal(v)
+	if err != nil {
+		return nil, err
+	}
+	if d.scale < scale {
+		shift := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale-d.scale)), nil)
+		d.unscaled.Mul(d.unscaled, shift)
+		d.scale = scale
+	}
+	return d, nil
+}
+
+func parseDecimal(v string) (Value, error) {
+	return toDecimal(v, 0)
+}
+
+func toDecimalString(v Value) (string, error) {
+	switch val := v.(type) {
+	case Decimal:
+		return val.String(), nil
+	default:
+		return "", fmt.Errorf("mapi: unsupported type")
+	}
+}
@@ -0,0 +1,94 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapi
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestDecimal(t *testing.T) {
+	t.Run("Verify decimals are parsed exactly", func(t *testing.T) {
+		tests := []struct {
+			s        string
+			unscaled string
+			scale    int
+			rat      string
+		}{
+			{"12.3400", "123400", 4, "617/50"},
+			{"-0.05", "-5", 2, "-1/20"},
+			{"123456789012345678.9012", "1234567890123456789012", 4, "308641972530864197253/2500"},
+			{"42", "42", 0, "42/1"},
+		}
+		for _, tt := range tests {
+			d, err := ParseDecimal(tt.s)
+			if err != nil {
+				t.Errorf("Error parsing %s: %v", tt.s, err)
+				continue
+			}
+			if d.Unscaled().String() != tt.unscaled || d.Scale() != tt.scale {
+				t.Errorf("Unexpected decimal for %s: %s, %d", tt.s, d.Unscaled(), d.Scale())
+			}
+			if d.String() != tt.s {
+				t.Errorf("Unexpected string for %s: %s", tt.s, d)
+			}
+			if d.Rat().String() != tt.rat {
+				t.Errorf("Unexpected rat for %s: %s", tt.s, d.Rat())
+			}
+		}
+		if _, err := ParseDecimal("1e5"); err == nil {
+			t.Error("Expected an error for an invalid decimal")
+		}
+	})
+
+	t.Run("Verify scale of the column", func(t *testing.T) {
+		v, err := toDecimal("1.5", 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := v.(Decimal).String(); s != "1.500" {
+			t.Errorf("Unexpected decimal: %s", s)
+		}
+	})
+
+	t.Run("Verify scan", func(t *testing.T) {
+		tests := []struct {
+			src      interface{}
+			expected string
+		}{
+			{NewDecimal(big.NewInt(1005), 2), "10.05"},
+			{"10.05", "10.05"},
+			{[]byte("-3.10"), "-3.10"},
+			{int64(7), "7"},
+			{0.25, "0.25"},
+		}
+		for _, tt := range tests {
+			var d Decimal
+			if err := d.Scan(tt.src); err != nil {
+				t.Errorf("Error scanning %v: %v", tt.src, err)
+				continue
+			}
+			if d.String() != tt.expected {
+				t.Errorf("Unexpected decimal for %v: %s", tt.src, d)
+			}
+		}
+		var d Decimal
+		if err := d.Scan(nil); err == nil {
+			t.Error("Expected an error for NULL")
+		}
+	})
+	t.Run("Verify scan into big.Rat", func(t *testing.T) {
+		r := new(big.Rat)
+		if err := (RatScanner{R: r}).Scan([]byte("-3.10")); err != nil {
+			t.Fatal(err)
+		}
+		if r.String() != "-31/10" {
+			t.Errorf("Unexpected rat: %s", r)
+		}
+		if err := (RatScanner{R: r}).Scan(nil); err == nil {
+			t.Error("Expected an error for NULL")
+		}
+	})
+}
@@ -0,0 +1,152 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapi
+
+import (
+	"errors"
+	"strings"
+)
+
+// The classes of errors that can be detected with errors.Is. ErrSyntax only
+// matches the errors of SQLSTATE 42000 that mention a syntax error, MonetDB
+// reports more problems with that code. For example
+//
+//	if errors.Is(err, mapi.ErrTransactionConflict) {
+//		// retry the transaction
+//	}
+var (
+	ErrConstraintViolation = errors.New("mapi: constraint violation")
+	ErrSyntax              = errors.New("mapi: syntax error")
+	ErrMissingObject       = errors.New("mapi: missing object")
+	ErrTransactionConflict = errors.New("mapi: transaction conflict")
+)
+
+// Error is an error that the server reports for a statement. Use errors.As to
+// get the SQLSTATE code of the error.
+//
+// When the server sends several errors for a statement, the following ones
+// are linked with Next. They are included in the message, and errors.Is
+// checks all of them.
+type Error struct {
+	// Code is the SQLSTATE of the error, it is empty when the server did
+	// not send one
+	Code    string
+	Message string
+	Next    *Error
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	b.WriteString("mapi: operational error: ")
+	for err := e; err != nil; err = err.Next {
+		if err != e {
+			b.WriteString("; ")
+		}
+		if err.Code != "" {
+			b.WriteString(err.Code)
+			b.WriteString("!")
+		}
+		b.WriteString(err.Message)
+	}
+	return b.String()
+}
+
+// Unwrap returns the next error of the statement, so errors.Is and errors.As
+// look at all of them
+func (e *Error) Unwrap() error {
+	if e.Next == nil {
+		return nil
+	}
+	return e.Next
+}
+
+// Is reports whether the SQLSTATE of the error belongs to the class of the
+// target
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrConstraintViolation:
+		// MonetDB reports violated keys with 40002
+		return strings.HasPrefix(e.Code, "23") || e.Code == "40002"
+	case ErrSyntax:
+		// MonetDB also uses 42000 for other errors, like a missing privilege
+		return e.Code == "42000" && strings.Contains(strings.ToLower(e.Message), "syntax error")
+	case ErrMissingObject:
+		switch e.Code {
+		case "3F000", "42S02", "42S12", "42S22":
+			return true
+		}
+	case ErrTransactionConflict:
+		return e.Code == "40000" || e.Code == "40001"
+	}
+	return false
+}
+
+// SQLState returns the SQLSTATE of the first server error in the chain of
+// err, or an empty string
+func SQLState(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return ""
+}
+
+// parseError returns the error of an error line of the server, which has
+// the form "!42S02!message" or "!message"
+func parseError(line string) *Error {
+	msg := strings.TrimPrefix(line, mapi_MSG_ERROR)
+	if len(msg) > 5 && msg[5] == '!' && isSQLState(msg[:5]) {
+		return &Error{Code: msg[:5], Message: msg[6:]}
+	}
+	return &Error{Message: msg}
+}
+
+func isSQLState(code string) bool {
+	for _, c := range code {
+		if !(c >= '0' && c <= '9' || c >= 'A' && c <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// errorOf returns the error for the error lines of a response
+func errorOf(lines []string) error {
+	var first, last *Error
+	for _, line := range lines {
+		if !strings.HasPrefix(line, mapi_MSG_ERROR) {
+			continue
+		}
+		e := parseError(line)
+		if first == nil {
+			first = e
+		} else {
+			last.Next = e
+		}
+		last = e
+	}
+	if first == nil {
+		return nil
+	}
+	return first
+}
+
+// readError returns the error of an error line, together with the error
+// lines that directly follow it in the response
+func readError(line string, r Response) error {
+	lines := []string{line}
+	for {
+		next, err := r.ReadLine()
+		if err != nil {
+			break
+		}
+		if !strings.HasPrefix(next, mapi_MSG_ERROR) {
+			r.UnreadLine()
+			break
+		}
+		lines = append(lines, next)
+	}
+	return errorOf(lines)
+}
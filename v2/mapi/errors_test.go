@@ -0,0 +1,104 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapi
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestParseError(t *testing.T) {
+	tcs := []struct {
+		line     string
+		expected Error
+	}{
+		{"!42S02!SELECT: no such table 'foo'", Error{Code: "42S02", Message: "SELECT: no such table 'foo'"}},
+		{"!40000!COMMIT: transaction is aborted because of concurrency conflicts, will ROLLBACK instead", Error{Code: "40000", Message: "COMMIT: transaction is aborted because of concurrency conflicts, will ROLLBACK instead"}},
+		{"!InvalidCredentialsException:checkCredentials:invalid credentials for user 'monetdb'", Error{Message: "InvalidCredentialsException:checkCredentials:invalid credentials for user 'monetdb'"}},
+		{"!short", Error{Message: "short"}},
+	}
+	for _, tc := range tcs {
+		if e := parseError(tc.line); *e != tc.expected {
+			t.Errorf("Unexpected error for %s: %+v", tc.line, e)
+		}
+	}
+}
+
+func TestErrorClasses(t *testing.T) {
+	tcs := []struct {
+		code    string
+		message string
+		class   error
+	}{
+		{"23000", "error", ErrConstraintViolation},
+		{"40002", "error", ErrConstraintViolation},
+		{"42000", "syntax error, unexpected IDENT", ErrSyntax},
+		{"42000", "SELECT: access denied for user 'me'", nil},
+		{"42S02", "error", ErrMissingObject},
+		{"3F000", "error", ErrMissingObject},
+		{"40000", "error", ErrTransactionConflict},
+		{"40001", "error", ErrTransactionConflict},
+	}
+	classes := []error{ErrConstraintViolation, ErrSyntax, ErrMissingObject, ErrTransactionConflict}
+	for _, tc := range tcs {
+		err := fmt.Errorf("statement failed: %w", &Error{Code: tc.code, Message: tc.message})
+		for _, class := range classes {
+			if errors.Is(err, class) != (class == tc.class) {
+				t.Errorf("Unexpected class of %s: %v", tc.code, class)
+			}
+		}
+		if SQLState(err) != tc.code {
+			t.Errorf("Unexpected SQLSTATE: %s", SQLState(err))
+		}
+	}
+}
+
+func TestErrorLines(t *testing.T) {
+	t.Run("Verify errors of a command", func(t *testing.T) {
+		c, server := newTestConn(t)
+		go func() {
+			readMessage(server)
+			writeMessage(server, "!42000!syntax error in: \"selec\"\n!42S02!no such table 'x'\n")
+		}()
+
+		_, err := c.Execute("selec * from x")
+		var e *Error
+		if !errors.As(err, &e) {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if e.Code != "42000" || e.Next == nil || e.Next.Code != "42S02" {
+			t.Errorf("Unexpected error: %+v", e)
+		}
+		if !errors.Is(err, ErrSyntax) || !errors.Is(err, ErrMissingObject) {
+			t.Errorf("Unexpected classes of error: %v", err)
+		}
+		expected := "mapi: operational error: 42000!syntax error in: \"selec\"; 42S02!no such table 'x'"
+		if err.Error() != expected {
+			t.Errorf("Unexpected message: %s", err)
+		}
+	})
+
+	t.Run("Verify errors of a query", func(t *testing.T) {
+		c, server := newTestConn(t)
+		go func() {
+			readMessage(server)
+			writeMessage(server, "&2 1 -1\n!40002!INSERT INTO: PRIMARY KEY constraint 't.t_id_pkey' violated\n!M0M29!second\n")
+		}()
+
+		q := NewQuery(c, "INSERT INTO t VALUES (1)")
+		err := q.OpenQuery()
+		if !errors.Is(err, ErrConstraintViolation) {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		var e *Error
+		if errors.As(err, &e) && (e.Next == nil || e.Next.Code != "M0M29") {
+			t.Errorf("Unexpected error: %+v", e)
+		}
+		if c.pending != nil {
+			t.Error("The connection is not released")
+		}
+	})
+}
@@ -0,0 +1,311 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapi
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// The server answers every chunk of an upload with either mapi_MSG_MORE,
+// when it wants more data, or mapi_MSG_FILETRANS, when it wants the client
+// to stop sending.
+var (
+	mapi_MSG_FILETRANS = string([]byte{1, 3, 10})
+)
+
+// After this many bytes the client waits for the server to tell whether it
+// wants more data.
+var uploadChunkSize = 1024 * 1024
+
+// Uploader provides the data for a COPY INTO ... FROM 'file' ON CLIENT
+// statement.
+//
+// Upload is called with the file name as it is written in the statement. The
+// binary flag is set for COPY BINARY INTO. When an error is returned, the
+// server aborts the statement and reports the error message. When the returned
+// reader implements io.Closer, it is closed when the upload is finished, or
+// when the server cancels the upload because it does not need more data.
+//
+// For text uploads with an OFFSET clause the lines before the offset are
+// skipped by the driver, the reader always starts at the beginning of the file.
+type Uploader interface {
+	Upload(filename string, binary bool) (io.Reader, error)
+}
+
+// UploaderFunc is an adapter to use an ordinary function as Uploader
+type UploaderFunc func(filename string, binary bool) (io.Reader, error)
+
+func (f UploaderFunc) Upload(filename string, binary bool) (io.Reader, error) {
+	return f(filename, binary)
+}
+
+// Downloader receives the data of a COPY SELECT ... INTO 'file' ON CLIENT
+// statement.
+//
+// Download is called with the file name as it is written in the statement.
+// The reader returns the data as it arrives from the server, so the result of
+// the query does not have to fit in memory. When an error is returned before
+// anything is read from the reader, the server aborts the statement with the
+// error message. Otherwise the remaining data is discarded and the error is
+// returned to the caller of the statement.
+type Downloader interface {
+	Download(filename string, r io.Reader) error
+}
+
+// DownloaderFunc is an adapter to use an ordinary function as Downloader
+type DownloaderFunc func(filename string, r io.Reader) error
+
+func (f DownloaderFunc) Download(filename string, r io.Reader) error {
+	return f(filename, r)
+}
+
+// getResponse reads the complete response of the server. When the server
+// needs a file transfer to complete the statement, the request is handled and
+// the rest of the response is read.
+func (c *mapiConn) getResponse() ([]byte, error) {
+	r := c.newResponse()
+	var resp bytes.Buffer
+	for {
+		line, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		if r.done {
+			return resp.Bytes(), nil
+		}
+		resp.WriteString(line)
+		resp.WriteByte('\n')
+	}
+}
+
+// transferError is the error of a file transfer handler that failed after
+// the transfer was started
+type transferError struct {
+	err error
+}
+
+func (e *transferError) Error() string {
+	return fmt.Sprintf("mapi: file transfer failed: %v", e.err)
+}
+
+func (e *transferError) Unwrap() error {
+	return e.err
+}
+
+func (c *mapiConn) handleFileTransfer(request string) error {
+	// During a transfer the data is mixed with our own messages, so an
+	// interrupt cannot be sent in the stream
+	c.setTransferring(true)
+	defer c.setTransferring(false)
+
+	cmd, args, _ := Cut(request, " ")
+	switch cmd {
+	case "r":
+		offset, filename, _ := Cut(args, " ")
+		n, err := strconv.Atoi(offset)
+		if err != nil {
+			return fmt.Errorf("mapi: invalid file transfer request: %s", request)
+		}
+		return c.handleUpload(filename, false, n)
+	case "rb":
+		return c.handleUpload(args, true, 0)
+	case "w":
+		return c.handleDownload(args)
+	default:
+		return c.putBlock([]byte(fmt.Sprintf("unsupported file transfer request: %s\n", cmd)))
+	}
+}
+
+// handleUpload answers an upload request of the server. The first line of the
+// answer is empty when the upload is accepted, otherwise it contains the error
+// message.
+func (c *mapiConn) handleUpload(filename string, binary bool, offset int) error {
+	if c.Uploader == nil {
+		return c.putBlock([]byte("No upload handler has been registered\n"))
+	}
+
+	r, err := c.Uploader.Upload(filename, binary)
+	if err != nil {
+		msg := strings.ReplaceAll(err.Error(), "\n", " ")
+		return c.putBlock([]byte(msg + "\n"))
+	}
+	if closer, ok := r.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	// The offset is the number of the first line that must be uploaded
+	if !binary && offset > 1 {
+		r = &lineSkipper{r: bufio.NewReader(r), skip: offset - 1}
+	}
+
+	return c.sendUpload(r)
+}
+
+// sendUpload sends the data in chunks. After each chunk the server tells
+// whether it wants more data. At the end of the data an empty message is sent.
+func (c *mapiConn) sendUpload(r io.Reader) error {
+	w := &messageWriter{c: c}
+	buf := make([]byte, mapi_MAX_PACKAGE_LENGTH)
+
+	w.Write([]byte("\n"))
+	for {
+		size := len(buf)
+		if left := uploadChunkSize - w.size; left < size {
+			size = left
+		}
+
+		n, err := r.Read(buf[:size])
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return werr
+			}
+		}
+		if w.size >= uploadChunkSize {
+			more, perr := w.flushChunk()
+			if perr != nil || !more {
+				return perr
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// At this point we cannot tell the server that the upload failed.
+			// Closing the connection makes sure that the partial data is not
+			// committed.
+			c.Disconnect()
+			return fmt.Errorf("mapi: upload failed: %w", err)
+		}
+	}
+
+	if w.size > 0 {
+		more, err := w.flushChunk()
+		if err != nil || !more {
+			return err
+		}
+	}
+	return c.putBlock(nil)
+}
+
+// messageWriter sends a message as a sequence of blocks. The message is
+// ended by calling flushChunk.
+type messageWriter struct {
+	c    *mapiConn
+	buf  []byte
+	size int
+}
+
+func (w *messageWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		free := mapi_MAX_PACKAGE_LENGTH - len(w.buf)
+		if free > len(p) {
+			free = len(p)
+		}
+		w.buf = append(w.buf, p[:free]...)
+		w.size += free
+		p = p[free:]
+
+		if len(w.buf) == mapi_MAX_PACKAGE_LENGTH {
+			if err := w.c.writeBlock(w.buf, false); err != nil {
+				return 0, err
+			}
+			w.buf = w.buf[:0]
+		}
+	}
+	return n, nil
+}
+
+// flushChunk ends the current message and reads the prompt of the server.
+// It returns false when the server does not want more data.
+func (w *messageWriter) flushChunk() (bool, error) {
+	if err := w.c.writeBlock(w.buf, true); err != nil {
+		return false, err
+	}
+	w.buf = w.buf[:0]
+	w.size = 0
+
+	prompt, err := w.c.getBlock()
+	if err != nil {
+		return false, err
+	}
+	switch string(prompt) {
+	case mapi_MSG_MORE:
+		return true, nil
+	case mapi_MSG_FILETRANS:
+		return false, nil
+	default:
+		return false, fmt.Errorf("mapi: unexpected response during upload: %q", prompt)
+	}
+}
+
+// lineSkipper discards the first lines of the underlying reader
+type lineSkipper struct {
+	r    *bufio.Reader
+	skip int
+}
+
+func (l *lineSkipper) Read(p []byte) (int, error) {
+	for l.skip > 0 {
+		if _, err := l.r.ReadSlice('\n'); err != nil {
+			if err == bufio.ErrBufferFull {
+				continue
+			}
+			return 0, err
+		}
+		l.skip--
+	}
+	return l.r.Read(p)
+}
+
+// handleDownload answers a download request of the server. Like an upload,
+// the first line of the answer is empty when the download is accepted. The
+// data follows as a single message from the server.
+func (c *mapiConn) handleDownload(filename string) error {
+	if c.Downloader == nil {
+		return c.putBlock([]byte("No download handler has been registered\n"))
+	}
+
+	r := &downloadReader{c: c, msg: messageReader{c: c}}
+	err := c.Downloader.Download(filename, r)
+	if err != nil && !r.started {
+		msg := strings.ReplaceAll(err.Error(), "\n", " ")
+		return c.putBlock([]byte(msg + "\n"))
+	}
+
+	// The server only continues with the statement after it has sent all the
+	// data, so whatever the handler did not read is discarded.
+	if _, derr := io.Copy(io.Discard, r); derr != nil {
+		return derr
+	}
+	if err != nil {
+		return &transferError{err}
+	}
+	return nil
+}
+
+// downloadReader reads the data of a download directly from the blocks that
+// the server sends. The download is accepted on the first read.
+type downloadReader struct {
+	c       *mapiConn
+	started bool
+	msg     messageReader
+}
+
+func (r *downloadReader) Read(p []byte) (int, error) {
+	if !r.started {
+		r.started = true
+		if err := r.c.putBlock([]byte("\n")); err != nil {
+			return 0, err
+		}
+	}
+
+	return r.msg.Read(p)
+}
@@ -0,0 +1,260 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+// readMessage reads a complete message on the server side of a test connection
+func readMessage(conn net.Conn) ([]byte, error) {
+	msg := make([]byte, 0)
+	for {
+		var flag uint16
+		if err := binary.Read(conn, binary.LittleEndian, &flag); err != nil {
+			return nil, err
+		}
+		data := make([]byte, flag>>1)
+		if _, err := io.ReadFull(conn, data); err != nil {
+			return nil, err
+		}
+		msg = append(msg, data...)
+		if flag&1 == 1 {
+			return msg, nil
+		}
+	}
+}
+
+// writeMessage writes a message on the server side of a test connection
+func writeMessage(conn net.Conn, msg string) error {
+	c := mapiConn{conn: conn}
+	return c.putBlock([]byte(msg))
+}
+
+func newTestConn(t testing.TB) (*mapiConn, net.Conn) {
+	client, server := net.Pipe()
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	c := NewMapiWithConfig(DefaultConfig())
+	c.conn = client
+	c.State = mapi_STATE_READY
+	return c, server
+}
+
+func TestUpload(t *testing.T) {
+	t.Run("Verify upload with offset", func(t *testing.T) {
+		c, server := newTestConn(t)
+		var requested string
+		c.Uploader = UploaderFunc(func(filename string, binary bool) (io.Reader, error) {
+			requested = filename
+			return strings.NewReader("1|a\n2|b\n3|c\n"), nil
+		})
+
+		data := make(chan []byte, 1)
+		go func() {
+			readMessage(server)
+			writeMessage(server, mapi_MSG_MORE+"r 2 /tmp/data.csv\n")
+			msg, _ := readMessage(server)
+			data <- msg
+			writeMessage(server, mapi_MSG_MORE)
+			if end, _ := readMessage(server); len(end) != 0 {
+				data <- end
+			}
+			writeMessage(server, "&2 2 -1\n")
+		}()
+
+		resp, err := c.Execute("COPY INTO t FROM '/tmp/data.csv' ON CLIENT")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if requested != "/tmp/data.csv" {
+			t.Errorf("Unexpected file name: %s", requested)
+		}
+		if msg := <-data; string(msg) != "\n2|b\n3|c\n" {
+			t.Errorf("Unexpected upload: %q", msg)
+		}
+		if resp != "&2 2 -1\n" {
+			t.Errorf("Unexpected response: %q", resp)
+		}
+	})
+
+	t.Run("Verify upload is cancelled by the server", func(t *testing.T) {
+		defer func(size int) { uploadChunkSize = size }(uploadChunkSize)
+		uploadChunkSize = 16
+
+		c, server := newTestConn(t)
+		input := strings.NewReader(strings.Repeat("0123456789\n", 100))
+		c.Uploader = UploaderFunc(func(filename string, binary bool) (io.Reader, error) {
+			return input, nil
+		})
+
+		go func() {
+			readMessage(server)
+			writeMessage(server, mapi_MSG_MORE+"rb data.bin\n")
+			readMessage(server)
+			writeMessage(server, mapi_MSG_FILETRANS)
+			writeMessage(server, "&2 1 -1\n")
+		}()
+
+		resp, err := c.Execute("COPY 1 RECORDS INTO t FROM 'data.bin' ON CLIENT")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp != "&2 1 -1\n" {
+			t.Errorf("Unexpected response: %q", resp)
+		}
+		if input.Len() == 0 {
+			t.Error("Upload was not cancelled")
+		}
+	})
+
+	t.Run("Verify upload is rejected", func(t *testing.T) {
+		c, server := newTestConn(t)
+		c.Uploader = UploaderFunc(func(filename string, binary bool) (io.Reader, error) {
+			return nil, errors.New("access denied")
+		})
+
+		data := make(chan []byte, 1)
+		go func() {
+			readMessage(server)
+			writeMessage(server, mapi_MSG_MORE+"r 0 secret.csv\n")
+			msg, _ := readMessage(server)
+			data <- msg
+			writeMessage(server, "!access denied\n")
+		}()
+
+		if _, err := c.Execute("COPY INTO t FROM 'secret.csv' ON CLIENT"); err == nil {
+			t.Error("Expected an error")
+		}
+		if msg := <-data; !bytes.Equal(msg, []byte("access denied\n")) {
+			t.Errorf("Unexpected answer: %q", msg)
+		}
+	})
+
+	t.Run("Verify upload without uploader", func(t *testing.T) {
+		c, server := newTestConn(t)
+
+		data := make(chan []byte, 1)
+		go func() {
+			readMessage(server)
+			writeMessage(server, mapi_MSG_MORE+"r 0 data.csv\n")
+			msg, _ := readMessage(server)
+			data <- msg
+			writeMessage(server, "!no upload handler\n")
+		}()
+
+		if _, err := c.Execute("COPY INTO t FROM 'data.csv' ON CLIENT"); err == nil {
+			t.Error("Expected an error")
+		}
+		if msg := <-data; len(msg) < 2 || msg[len(msg)-1] != '\n' {
+			t.Errorf("Unexpected answer: %q", msg)
+		}
+	})
+}
+
+func TestDownload(t *testing.T) {
+	t.Run("Verify download", func(t *testing.T) {
+		c, server := newTestConn(t)
+		var requested string
+		var received bytes.Buffer
+		c.Downloader = DownloaderFunc(func(filename string, r io.Reader) error {
+			requested = filename
+			_, err := io.Copy(&received, r)
+			return err
+		})
+
+		data := strings.Repeat("1|name1\n", 2000)
+		answer := make(chan []byte, 1)
+		go func() {
+			readMessage(server)
+			writeMessage(server, mapi_MSG_MORE+"w /tmp/out.csv\n")
+			msg, _ := readMessage(server)
+			answer <- msg
+			writeMessage(server, data)
+			writeMessage(server, "&2 2000 -1\n")
+		}()
+
+		resp, err := c.Execute("COPY SELECT * FROM t INTO '/tmp/out.csv' ON CLIENT")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg := <-answer; string(msg) != "\n" {
+			t.Errorf("Unexpected answer: %q", msg)
+		}
+		if requested != "/tmp/out.csv" {
+			t.Errorf("Unexpected file name: %s", requested)
+		}
+		if received.String() != data {
+			t.Errorf("Unexpected download of %d bytes, expected %d", received.Len(), len(data))
+		}
+		if resp != "&2 2000 -1\n" {
+			t.Errorf("Unexpected response: %q", resp)
+		}
+	})
+
+	t.Run("Verify download is rejected", func(t *testing.T) {
+		c, server := newTestConn(t)
+		c.Downloader = DownloaderFunc(func(filename string, r io.Reader) error {
+			return errors.New("access denied")
+		})
+
+		answer := make(chan []byte, 1)
+		go func() {
+			readMessage(server)
+			writeMessage(server, mapi_MSG_MORE+"w /tmp/out.csv\n")
+			msg, _ := readMessage(server)
+			answer <- msg
+			writeMessage(server, "!access denied\n")
+		}()
+
+		if _, err := c.Execute("COPY SELECT * FROM t INTO '/tmp/out.csv' ON CLIENT"); err == nil {
+			t.Error("Expected an error")
+		}
+		if msg := <-answer; string(msg) != "access denied\n" {
+			t.Errorf("Unexpected answer: %q", msg)
+		}
+	})
+
+	t.Run("Verify download fails halfway", func(t *testing.T) {
+		c, server := newTestConn(t)
+		failure := errors.New("disk full")
+		c.Downloader = DownloaderFunc(func(filename string, r io.Reader) error {
+			b := make([]byte, 10)
+			r.Read(b)
+			return failure
+		})
+
+		go func() {
+			readMessage(server)
+			writeMessage(server, mapi_MSG_MORE+"w /tmp/out.csv\n")
+			readMessage(server)
+			writeMessage(server, strings.Repeat("1|name1\n", 2000))
+			writeMessage(server, "&2 2000 -1\n")
+			readMessage(server)
+			writeMessage(server, "&3 1 1\n")
+		}()
+
+		_, err := c.Execute("COPY SELECT * FROM t INTO '/tmp/out.csv' ON CLIENT")
+		if !errors.Is(err, failure) {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		// The connection must still be usable
+		resp, err := c.Execute("SELECT 1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp != "&3 1 1\n" {
+			t.Errorf("Unexpected response: %q", resp)
+		}
+	})
+}
@@ -0,0 +1,140 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapi
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+var (
+	minInt128 = new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), 127))
+	maxInt128 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 127), big.NewInt(1))
+	twoTo128  = new(big.Int).Lsh(big.NewInt(1), 128)
+	mask64    = new(big.Int).SetUint64(math.MaxUint64)
+)
+
+// Int128 is a value of a HUGEINT column, a 128 bit integer in two's
+// complement. Hi holds the upper 64 bits, including the sign, Lo the lower
+// 64 bits. The driver returns the values of a HUGEINT column as int64, or as
+// *big.Int when they do not fit, both can be scanned into an Int128.
+type Int128 struct {
+	Hi int64
+	Lo uint64
+}
+
+// Int128FromInt64 returns the Int128 of v
+func Int128FromInt64(v int64) Int128 {
+	hi := int64(0)
+	if v < 0 {
+		hi = -1
+	}
+	return Int128{Hi: hi, Lo: uint64(v)}
+}
+
+// Int128FromBig returns the Int128 of b, or an error when b does not fit in
+// 128 bits
+func Int128FromBig(b *big.Int) (Int128, error) {
+	if b.Cmp(minInt128) < 0 || b.Cmp(maxInt128) > 0 {
+		return Int128{}, fmt.Errorf("mapi: %s does not fit in a hugeint", b)
+	}
+	v := new(big.Int).Set(b)
+	if v.Sign() < 0 {
+		v.Add(v, twoTo128)
+	}
+	lo := new(big.Int).And(v, mask64).Uint64()
+	hi := v.Rsh(v, 64).Uint64()
+	return Int128{Hi: int64(hi), Lo: lo}, nil
+}
+
+// Big returns the value as a *big.Int
+func (i Int128) Big() *big.Int {
+	b := new(big.Int).Lsh(big.NewInt(i.Hi), 64)
+	return b.Add(b, new(big.Int).SetUint64(i.Lo))
+}
+
+// IsInt64 reports whether the value fits in an int64
+func (i Int128) IsInt64() bool {
+	return (i.Hi == 0 && i.Lo <= math.MaxInt64) || (i.Hi == -1 && i.Lo > math.MaxInt64)
+}
+
+// String returns the value in decimal
+func (i Int128) String() string {
+	if i.IsInt64() {
+		return strconv.FormatInt(int64(i.Lo), 10)
+	}
+	return i.Big().String()
+}
+
+// Scan implements the sql.Scanner interface. It accepts the int64 and
+// *big.Int values of the driver, and strings.
+func (i *Int128) Scan(src interface{}) error {
+	var err error
+	switch v := src.(type) {
+	case Int128:
+		*i = v
+	case int64:
+		*i = Int128FromInt64(v)
+	case *big.Int:
+		*i, err = Int128FromBig(v)
+	case string:
+		*i, err = parseInt128(v)
+	case []byte:
+		*i, err = parseInt128(string(v))
+	case nil:
+		err = fmt.Errorf("mapi: cannot scan NULL into an Int128")
+	default:
+		err = fmt.Errorf("mapi: cannot scan %T into an Int128", src)
+	}
+	return err
+}
+
+// Value implements the driver.Valuer interface. A value that does not fit
+// in an int64 is returned as a string.
+func (i Int128) Value() (driver.Value, error) {
+	if i.IsInt64() {
+		return int64(i.Lo), nil
+	}
+	return i.String(), nil
+}
+
+func parseInt128(s string) (Int128, error) {
+	b, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return Int128{}, fmt.Errorf("mapi: invalid hugeint: %q", s)
+	}
+	return Int128FromBig(b)
+}
+
+// toHugeint converts a value of a HUGEINT column to an int64, or to a
+// *big.Int when it is out of the range of an int64
+func toHugeint(v string) (Value, error) {
+	i, err := strconv.ParseInt(v, 10, 64)
+	if err == nil {
+		return i, nil
+	}
+	b, ok := new(big.Int).SetString(v, 10)
+	if !ok {
+		return nil, err
+	}
+	return b, nil
+}
+
+func toBigIntString(v Value) (string, error) {
+	switch val := v.(type) {
+	case *big.Int:
+		if val == nil {
+			return "NULL", nil
+		}
+		return val.String(), nil
+	case Int128:
+		return val.String(), nil
+	default:
+		return "", fmt.Errorf("mapi: unsupported type")
+	}
+}
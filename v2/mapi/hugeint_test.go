@@ -0,0 +1,61 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapi
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestInt128(t *testing.T) {
+	t.Run("Verify conversion from and to big.Int", func(t *testing.T) {
+		tests := []string{
+			"0",
+			"-1",
+			"9223372036854775807",
+			"-9223372036854775808",
+			"9223372036854775808",
+			"-9223372036854775809",
+			"170141183460469231731687303715884105727",
+			"-170141183460469231731687303715884105728",
+		}
+		for _, s := range tests {
+			b, _ := new(big.Int).SetString(s, 10)
+			i, err := Int128FromBig(b)
+			if err != nil {
+				t.Errorf("Error converting %s: %v", s, err)
+				continue
+			}
+			if i.String() != s || i.Big().Cmp(b) != 0 {
+				t.Errorf("Unexpected value for %s: %s", s, i)
+			}
+			if i.IsInt64() != b.IsInt64() {
+				t.Errorf("Unexpected IsInt64 for %s", s)
+			}
+		}
+		b, _ := new(big.Int).SetString("170141183460469231731687303715884105728", 10)
+		if _, err := Int128FromBig(b); err == nil {
+			t.Error("Expected an error for a value out of range")
+		}
+	})
+
+	t.Run("Verify hugeint values", func(t *testing.T) {
+		v, err := convertToGo("42", MDB_HUGEINT)
+		if err != nil || v != int64(42) {
+			t.Errorf("Unexpected value: %v, %v", v, err)
+		}
+		v, err = convertToGo("-12345678901234567890123", MDB_HUGEINT)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b, ok := v.(*big.Int); !ok || b.String() != "-12345678901234567890123" {
+			t.Errorf("Unexpected value: %#v", v)
+		}
+		var i Int128
+		if err := i.Scan(v); err != nil || i.String() != "-12345678901234567890123" {
+			t.Errorf("Unexpected scan: %v, %v", i, err)
+		}
+	})
+}
@@ -0,0 +1,140 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapi
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+// The value of the out-of-band message that interrupts the running statement
+const mapi_OOB_INTERRUPT = 1
+
+// On a Unix domain socket out-of-band data is not available. Instead the
+// message is sent in the stream as a 0xff byte, the value and a 0 byte, like
+// socket_putoob_unix of the server does.
+var mapi_OOB_UNIX_INTERRUPT = []byte{0xff, mapi_OOB_INTERRUPT, 0}
+
+// The query for the id of the session, which is needed to stop a running
+// statement with sys.stop
+const mapi_SESSION_ID_QUERY = "sSELECT sys.current_sessionid();"
+
+// Time that the separate connection gets to stop a session, so Interrupt
+// returns when the server does not answer
+var stopTimeout = 10 * time.Second
+
+// Interrupt asks the server to stop the command that is running on this
+// connection. It is meant to be called from another goroutine than the one
+// that waits for the response. The interrupted command returns with an error
+// from the server, after which the connection can be used again.
+//
+// When no command is running, for example because it is not sent yet, an
+// error is returned, so the caller knows that nothing was interrupted and can
+// abort the connection instead of waiting for the command.
+//
+// Servers that advertise OOBINTR in the login challenge receive an
+// out-of-band message on the connection itself. Otherwise, when StopSession
+// is set in the Config, the session is stopped with sys.stop on a separate
+// connection, which requires that the user is allowed to stop its own
+// sessions.
+func (c *mapiConn) Interrupt() error {
+	c.mu.Lock()
+	if !c.running {
+		c.mu.Unlock()
+		return fmt.Errorf("mapi: no command is running")
+	}
+	if c.canSendOOB() && !c.transferring {
+		// Holding the lock makes sure that the message is not mixed up with
+		// the blocks of a command that is still being sent
+		defer c.mu.Unlock()
+		if conn, ok := c.conn.(*net.TCPConn); ok {
+			return sendOOB(conn, mapi_OOB_INTERRUPT)
+		}
+		_, err := c.conn.Write(mapi_OOB_UNIX_INTERRUPT)
+		return err
+	}
+	sessionId := c.sessionId
+	c.mu.Unlock()
+	return c.stopSession(sessionId)
+}
+
+// Abort closes the network connection without waiting for the command that
+// is running. The command fails and the handle cannot be used anymore.
+func (c *mapiConn) Abort() {
+	if c.conn != nil {
+		c.conn.Close()
+	}
+}
+
+// stopSession stops the running statement of this session from a separate
+// connection to the same server. Connecting and stopping the session are
+// limited by the stopTimeout.
+func (c *mapiConn) stopSession(sessionId int) error {
+	if sessionId < 0 {
+		return fmt.Errorf("mapi: the server does not support interrupting a statement")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), stopTimeout)
+	defer cancel()
+
+	side := NewMapiWithConfig(c.Config.withEndpoint(c.endpoint))
+	side.TLSConfig = c.TLSConfig
+	side.ClientCert = c.ClientCert
+	if err := side.connect(ctx); err != nil {
+		return err
+	}
+	defer side.Disconnect()
+
+	stop := watchContext(ctx, side.conn)
+	defer stop()
+	_, err := side.Execute(fmt.Sprintf("CALL sys.stop(%d)", sessionId))
+	if ctx.Err() != nil {
+		return &TimeoutError{Op: "interrupt", Err: ctx.Err()}
+	}
+	return err
+}
+
+// canSendOOB tells whether a running statement can be interrupted with an
+// out-of-band message on this connection
+func (c *mapiConn) canSendOOB() bool {
+	if !c.oobIntr {
+		return false
+	}
+	switch c.conn.(type) {
+	case *net.TCPConn:
+		return oobSupported
+	case *net.UnixConn:
+		return true
+	}
+	return false
+}
+
+// setSessionId reads the id of the session from the response to the
+// mapi_SESSION_ID_QUERY. When it is not available, for example because the
+// server does not know the function, the session cannot be interrupted.
+func (c *mapiConn) setSessionId(resp []byte) {
+	q := NewQuery(nil, "")
+	if err := q.StoreResult(string(resp)); err != nil {
+		return
+	}
+	rs := q.Result()
+	if rs == nil || len(rs.Rows) != 1 || len(rs.Rows[0]) != 1 {
+		return
+	}
+	if id, err := strconv.Atoi(fmt.Sprint(rs.Rows[0][0])); err == nil {
+		c.mu.Lock()
+		c.sessionId = id
+		c.mu.Unlock()
+	}
+}
+
+func (c *mapiConn) setTransferring(transferring bool) {
+	c.mu.Lock()
+	c.transferring = transferring
+	c.mu.Unlock()
+}
@@ -0,0 +1,73 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapi
+
+import (
+	"context"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+)
+
+func TestInterruptTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, _ := l.Accept()
+		accepted <- conn
+	}()
+
+	c := NewMapiWithConfig(DefaultConfig())
+	c.Hostname = "127.0.0.1"
+	c.Port = l.Addr().(*net.TCPAddr).Port
+	conn, err := c.dialTCP(context.Background(), c.Config.endpoint())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	c.conn = conn
+	c.State = mapi_STATE_READY
+	c.oobIntr = true
+
+	server := <-accepted
+	if server == nil {
+		t.Fatal("no connection accepted")
+	}
+	defer server.Close()
+
+	// Receive the urgent data in the normal stream
+	raw, err := server.(*net.TCPConn).SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw.Control(func(fd uintptr) {
+		syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_OOBINLINE, 1)
+	})
+
+	received := make(chan []byte, 1)
+	go func() {
+		readMessage(server)
+		if err := c.Interrupt(); err != nil {
+			t.Error(err)
+		}
+		b := make([]byte, 1)
+		io.ReadFull(server, b)
+		received <- b
+		writeMessage(server, "!HY008!Query aborted\n")
+	}()
+
+	if _, err := c.Execute("SELECT * FROM long_running"); err == nil {
+		t.Error("Expected an error")
+	}
+	if b := <-received; b[0] != mapi_OOB_INTERRUPT {
+		t.Errorf("Unexpected interrupt message: %v", b)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapi
+
+import (
+	"fmt"
+	"net"
+)
+
+const oobSupported = false
+
+func sendOOB(conn *net.TCPConn, b byte) error {
+	return fmt.Errorf("mapi: out-of-band data is not supported on this platform")
+}
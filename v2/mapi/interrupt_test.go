@@ -0,0 +1,213 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapi
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/MonetDB/MonetDB-Go/v2/mapitest"
+)
+
+// newSocketTestConn connects a handle to a server on a Unix domain socket
+func newSocketTestConn(t *testing.T) (*mapiConn, net.Conn) {
+	l, err := net.Listen("unix", filepath.Join(t.TempDir(), ".s.monetdb.50000"))
+	if err != nil {
+		t.Skip("unix domain sockets not available")
+	}
+	defer l.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, _ := l.Accept()
+		accepted <- conn
+	}()
+
+	client, err := net.Dial("unix", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := <-accepted
+	if server == nil {
+		t.Fatal("no connection accepted")
+	}
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+
+	c := NewMapiWithConfig(DefaultConfig())
+	c.conn = client
+	c.State = mapi_STATE_READY
+	return c, server
+}
+
+func TestChallengeOptions(t *testing.T) {
+	c := NewMapiWithConfig(DefaultConfig())
+	if _, err := c.challengeResponse([]byte("salt:mserver:9:SHA1:LIT:SHA512:sql=6:BINARY=1:OOBINTR=1:")); err != nil {
+		t.Fatal(err)
+	}
+	if !c.oobIntr {
+		t.Error("Out-of-band interrupts not detected")
+	}
+
+	if _, err := c.challengeResponse([]byte("salt:mserver:9:SHA1:LIT:SHA512:")); err != nil {
+		t.Fatal(err)
+	}
+	if c.oobIntr {
+		t.Error("Unexpected out-of-band interrupts")
+	}
+
+	if _, err := c.challengeResponse([]byte("salt:mserver")); err == nil {
+		t.Error("Expected an error for an invalid challenge")
+	}
+}
+
+func TestInterrupt(t *testing.T) {
+	t.Run("Verify interrupt on unix socket", func(t *testing.T) {
+		c, server := newSocketTestConn(t)
+		c.oobIntr = true
+
+		received := make(chan []byte, 1)
+		go func() {
+			readMessage(server)
+			if err := c.Interrupt(); err != nil {
+				t.Error(err)
+			}
+			b := make([]byte, 3)
+			io.ReadFull(server, b)
+			received <- b
+			writeMessage(server, "!HY008!Query aborted\n")
+		}()
+
+		if _, err := c.Execute("SELECT * FROM long_running"); err == nil {
+			t.Error("Expected an error")
+		}
+		if b := <-received; !bytes.Equal(b, []byte{0xff, 0x01, 0x00}) {
+			t.Errorf("Unexpected interrupt message: %v", b)
+		}
+	})
+
+	t.Run("Verify interrupt without running command", func(t *testing.T) {
+		c, _ := newTestConn(t)
+		if err := c.Interrupt(); err == nil {
+			t.Error("Expected an error")
+		}
+	})
+
+	t.Run("Verify interrupt without session id", func(t *testing.T) {
+		c, server := newTestConn(t)
+
+		result := make(chan error, 1)
+		go func() {
+			readMessage(server)
+			result <- c.Interrupt()
+			writeMessage(server, "&2 0 -1\n")
+		}()
+
+		if _, err := c.Execute("SELECT 1"); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-result; err == nil {
+			t.Error("Expected an error")
+		}
+	})
+
+	t.Run("Verify abort releases the command", func(t *testing.T) {
+		c, server := newTestConn(t)
+
+		go func() {
+			readMessage(server)
+			c.Abort()
+		}()
+
+		if _, err := c.Execute("SELECT 1"); err == nil {
+			t.Error("Expected an error")
+		}
+	})
+}
+
+func TestStopSession(t *testing.T) {
+	srv := mapitest.NewServer(mapitest.HandlerFunc(func(r *mapitest.Request) mapitest.Reply {
+		return mapitest.Update{Count: 1, LastID: -1}
+	}))
+	defer srv.Close()
+
+	connect := func(t *testing.T, stopSession bool) *mapiConn {
+		cfg, err := ParseDSN(srv.DSN())
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.StopSession = stopSession
+		c := NewMapiWithConfig(cfg)
+		if err := c.Connect(); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(c.Disconnect)
+		return c
+	}
+
+	t.Run("Verify session id is asked with the first statement", func(t *testing.T) {
+		c := connect(t, true)
+		before := len(srv.Statements())
+		if c.sessionId != -1 {
+			t.Errorf("Session id asked during the login: %d", c.sessionId)
+		}
+		if _, err := c.Execute("SELECT 1"); err != nil {
+			t.Fatal(err)
+		}
+		if c.sessionId < 0 {
+			t.Error("Session id not set")
+		}
+		statements := srv.Statements()[before:]
+		if len(statements) != 2 || statements[0] != "SELECT sys.current_sessionid()" || statements[1] != "SELECT 1" {
+			t.Errorf("Unexpected statements: %q", statements)
+		}
+	})
+
+	t.Run("Verify session id is not asked by default", func(t *testing.T) {
+		c := connect(t, false)
+		before := len(srv.Statements())
+		if _, err := c.Execute("SELECT 1"); err != nil {
+			t.Fatal(err)
+		}
+		if statements := srv.Statements()[before:]; len(statements) != 1 || c.sessionId != -1 {
+			t.Errorf("Unexpected statements: %q", statements)
+		}
+	})
+
+	t.Run("Verify stopping a session times out", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close()
+		go func() {
+			// Accept the connection, but never send the challenge
+			conn, err := l.Accept()
+			if err == nil {
+				defer conn.Close()
+				io.Copy(io.Discard, conn)
+			}
+		}()
+
+		defer func(timeout time.Duration) { stopTimeout = timeout }(stopTimeout)
+		stopTimeout = 50 * time.Millisecond
+
+		c := NewMapiWithConfig(DefaultConfig())
+		c.endpoint = Endpoint{Hostname: "127.0.0.1", Port: l.Addr().(*net.TCPAddr).Port}
+		start := time.Now()
+		if err := c.stopSession(1); err == nil {
+			t.Error("Expected an error")
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Stopping the session took %v", elapsed)
+		}
+	})
+}
@@ -0,0 +1,59 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapi
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MonthInterval is a value of an INTERVAL MONTH or INTERVAL YEAR column, the
+// number of months. It cannot be a time.Duration, because the length of a
+// month varies.
+type MonthInterval int32
+
+// toDuration converts the seconds of a sec_interval or a day_interval,
+// which have three decimals like -1.500
+func toDuration(v string) (Value, error) {
+	d, err := ParseDecimal(v)
+	if err != nil {
+		return nil, fmt.Errorf("mapi: invalid interval: %q", v)
+	}
+	ns := new(big.Rat).Mul(d.Rat(), big.NewRat(int64(time.Second), 1))
+	if !ns.IsInt() || !ns.Num().IsInt64() {
+		return nil, fmt.Errorf("mapi: interval out of range: %q", v)
+	}
+	return time.Duration(ns.Num().Int64()), nil
+}
+
+func toMonthInterval(v string) (Value, error) {
+	months, err := strconv.ParseInt(v, 10, 32)
+	if err != nil {
+		return nil, err
+	}
+	return MonthInterval(months), nil
+}
+
+// toIntervalString renders an interval literal. The server keeps the
+// seconds in milliseconds, so a duration is rounded to milliseconds.
+func toIntervalString(v Value) (string, error) {
+	switch val := v.(type) {
+	case time.Duration:
+		ms := val.Round(time.Millisecond).Milliseconds()
+		sign := ""
+		if ms < 0 {
+			sign, ms = "-", -ms
+		}
+		seconds := fmt.Sprintf("%s%d.%03d", sign, ms/1000, ms%1000)
+		return fmt.Sprintf("INTERVAL '%s' SECOND", strings.TrimSuffix(seconds, ".000")), nil
+	case MonthInterval:
+		return fmt.Sprintf("INTERVAL '%d' MONTH", val), nil
+	default:
+		return "", fmt.Errorf("mapi: unsupported type")
+	}
+}
@@ -0,0 +1,74 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapi
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInterval(t *testing.T) {
+	t.Run("Verify seconds round trip", func(t *testing.T) {
+		tests := []struct {
+			text    string
+			value   time.Duration
+			literal string
+		}{
+			{"3600.000", time.Hour, "INTERVAL '3600' SECOND"},
+			{"-1.500", -1500 * time.Millisecond, "INTERVAL '-1.500' SECOND"},
+			{"0.001", time.Millisecond, "INTERVAL '0.001' SECOND"},
+			{"-0.250", -250 * time.Millisecond, "INTERVAL '-0.250' SECOND"},
+			{"172800.000", 48 * time.Hour, "INTERVAL '172800' SECOND"},
+		}
+		for _, tt := range tests {
+			for _, columnType := range []string{MDB_SEC_INTERVAL, MDB_DAY_INTERVAL} {
+				v, err := convertToGo(tt.text, columnType)
+				if err != nil {
+					t.Errorf("Error converting %s: %v", tt.text, err)
+					continue
+				}
+				if v != tt.value {
+					t.Errorf("Unexpected %s for %s: %v", columnType, tt.text, v)
+				}
+			}
+			literal, err := ConvertToMonet(tt.value)
+			if err != nil || literal != tt.literal {
+				t.Errorf("Unexpected literal for %v: %s, %v", tt.value, literal, err)
+			}
+		}
+	})
+
+	t.Run("Verify duration is rounded to milliseconds", func(t *testing.T) {
+		literal, _ := ConvertToMonet(-1500600 * time.Microsecond)
+		if literal != "INTERVAL '-1.501' SECOND" {
+			t.Errorf("Unexpected literal: %s", literal)
+		}
+	})
+
+	t.Run("Verify months round trip", func(t *testing.T) {
+		for _, months := range []MonthInterval{14, -3, 0} {
+			literal, err := ConvertToMonet(months)
+			if err != nil {
+				t.Fatal(err)
+			}
+			text := literal[len("INTERVAL '") : len(literal)-len("' MONTH")]
+			v, err := convertToGo(text, MDB_MONTH_INTERVAL)
+			if err != nil || v != months {
+				t.Errorf("Unexpected months for %s: %v, %v", literal, v, err)
+			}
+		}
+	})
+
+	t.Run("Verify interval parameters", func(t *testing.T) {
+		literal, _ := ConvertParameter(time.Second, Parameter{ColumnType: MDB_DAY_INTERVAL, Digits: 4})
+		if literal != "CAST(INTERVAL '1' SECOND AS interval day)" {
+			t.Errorf("Unexpected literal: %s", literal)
+		}
+		literal, _ = ConvertParameter(MonthInterval(2), Parameter{ColumnType: MDB_MONTH_INTERVAL, Digits: 3})
+		if literal != "INTERVAL '2' MONTH" {
+			t.Errorf("Unexpected literal: %s", literal)
+		}
+	})
+}
@@ -5,18 +5,19 @@
 package mapi
 
 import (
+	"bufio"
 	"bytes"
-	"crypto"
-	_ "crypto/md5"
-	_ "crypto/sha1"
-	_ "crypto/sha512"
+	"context"
+	"crypto/tls"
 	"encoding/binary"
+	"errors"
 	"fmt"
-	"hash"
-	"io"
+	"io/fs"
 	"net"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 )
 
@@ -56,31 +57,62 @@ var (
 
 type MapiConn interface {
 	Connect() error
+	ConnectContext(ctx context.Context) error
 	Disconnect()
 	Execute(query string) (string, error)
+	ExecuteStream(query string) (Response, error)
 	FetchNext(queryId int, offset int, amount int) (string, error)
+	FetchNextStream(queryId int, offset int, amount int) (Response, error)
 	SetSizeHeader(enable bool) (string, error)
 	SetReplySize(size int) (string, error)
 	SetAutoCommit(enable bool) (string, error)
 	SetServerTimezone(timezone *time.Location) error
+	Location() *time.Location
+	SetSchema(schema string) error
+	SetUploader(uploader Uploader)
+	SetDownloader(downloader Downloader)
+	SetMessageHandler(handler MessageHandler)
+	Messages() []Message
+	Interrupt() error
+	Abort()
+	SetDeadline(t time.Time)
+	Endpoint() Endpoint
+	Valid() bool
+	ResetSession() error
+	CloseResultSet(queryId int)
+	ReleasePrepared(execId int)
 }
 
 // MapiConn is a MonetDB's MAPI connection handle.
 //
-// The values in the handle are initially set according to the values
-// that are provided when calling NewMapi. However, they may change
-// depending on how the MonetDB server redirects the connection.
-// The final values are available after the connection is made by
-// calling the Connect() function.
+// The values in the handle are set according to the values that are
+// provided when calling NewMapi. The MonetDB server may redirect the
+// connection to another endpoint, which is available from the
+// Endpoint() function after the connection is made by calling the
+// Connect() function.
 //
 // The State value can be either MAPI_STATE_INIT or MAPI_STATE_READY.
 type mapiConn struct {
-	Hostname string
-	Port     int
-	Username string
-	Password string
-	Database string
-	Language string
+	Config
+
+	// These are only used when TLS is enabled, in addition to the
+	// certificate settings in the Config.
+	TLSConfig  *tls.Config
+	ClientCert *tls.Certificate
+
+	// Uploader and Downloader handle the file transfer requests of
+	// COPY ... ON CLIENT statements
+	Uploader   Uploader
+	Downloader Downloader
+
+	// MessageHandler receives the info and warning messages of the server
+	MessageHandler MessageHandler
+
+	// Tracer records the messages that are exchanged with the server. With
+	// a Replay the connection is made to a recorded transcript instead of
+	// the server.
+	Tracer *Tracer
+	Replay *Replay
 
 	State int
 
@@ -89,26 +121,81 @@ type mapiConn struct {
 	autoCommit bool
 	timezone   *time.Location
 
-	conn *net.TCPConn
+	// transaction is set while a transaction is open, changed is set when a
+	// SET statement may have changed the time zone or the schema
+	transaction bool
+	changed     bool
+
+	// broken is set after an error of the network connection
+	broken bool
+
+	// deferred holds the housekeeping commands that are sent together with
+	// the next command
+	deferred []string
+
+	// oobIntr is set when the server accepts out-of-band interrupts,
+	// otherwise the sessionId is used to stop a running statement. It is
+	// asked together with the next command while askSessionId is set.
+	oobIntr      bool
+	sessionId    int
+	askSessionId bool
+
+	// mu guards the connection while another goroutine interrupts the
+	// running command
+	mu           sync.Mutex
+	running      bool
+	transferring bool
+
+	// endpoint is where the connection ended up after the redirects
+	endpoint Endpoint
+
+	// handshake holds the session settings that were sent in the login
+	// response
+	handshake map[string]bool
+
+	// messages are the messages of the current statement
+	statement string
+	messages  []Message
+
+	// deadline applies to the commands that are sent, in addition to the
+	// read and write timeouts
+	deadline time.Time
+
+	// reader buffers the network connection, lines splits the current
+	// message into lines. pending is the response that is still being read,
+	// it is moved to memory before the next command is sent.
+	reader     *bufio.Reader
+	readerConn net.Conn
+	lines      *bufio.Reader
+	pending    *responseReader
+
+	// traceId is the number of the connection in the transcript, traced
+	// holds the part of the message that is being received
+	traceId int
+	traced  []byte
+
+	conn net.Conn
 }
 
 // NewMapi returns a MonetDB's MAPI connection handle.
 //
 // To establish the connection, call the Connect() function.
 func NewMapi(name string) (*mapiConn, error) {
-	var language = "sql"
 	c, err := parseDSN(name)
 	if err != nil {
 		return nil, err
 	}
 
+	return NewMapiWithConfig(c), nil
+}
+
+// NewMapiWithConfig returns a MonetDB's MAPI connection handle for an
+// already parsed configuration.
+//
+// To establish the connection, call the Connect() function.
+func NewMapiWithConfig(c Config) *mapiConn {
 	return &mapiConn{
-		Hostname: c.Hostname,
-		Port:     c.Port,
-		Username: c.Username,
-		Password: c.Password,
-		Database: c.Database,
-		Language: language,
+		Config: c,
 
 		State: mapi_STATE_INIT,
 
@@ -116,12 +203,15 @@ func NewMapi(name string) (*mapiConn, error) {
 		replySize:  MAPI_ARRAY_SIZE,
 		autoCommit: true,
 		timezone:   time.Local,
-	}, nil
+
+		sessionId: -1,
+	}
 }
 
 // Disconnect closes the connection.
 func (c *mapiConn) Disconnect() {
 	c.State = mapi_STATE_INIT
+	c.pending = nil
 	if c.conn != nil {
 		c.conn.Close()
 		c.conn = nil
@@ -133,11 +223,45 @@ func (c *mapiConn) Execute(query string) (string, error) {
 	return c.cmd(cmd)
 }
 
+// ExecuteStream sends the query like Execute, but returns the response while
+// it arrives. The connection cannot be used for another command until the
+// response is read. When another command is sent anyway, the rest of the
+// response is read into memory first.
+func (c *mapiConn) ExecuteStream(query string) (Response, error) {
+	cmd := fmt.Sprintf("s%s;", query)
+	return c.stream(cmd)
+}
+
 func (c *mapiConn) FetchNext(queryId int, offset int, amount int) (string, error) {
 	cmd := fmt.Sprintf("Xexport %d %d %d", queryId, offset, amount)
 	return c.cmd(cmd)
 }
 
+// FetchNextStream is like FetchNext, but returns the rows while they arrive
+func (c *mapiConn) FetchNextStream(queryId int, offset int, amount int) (Response, error) {
+	cmd := fmt.Sprintf("Xexport %d %d %d", queryId, offset, amount)
+	return c.stream(cmd)
+}
+
+// CloseResultSet closes a result set of which the server still holds rows
+// that were not fetched. The command is sent together with the next command,
+// which saves a round trip.
+func (c *mapiConn) CloseResultSet(queryId int) {
+	c.sendLater(fmt.Sprintf("Xclose %d", queryId))
+}
+
+// ReleasePrepared deallocates a prepared statement on the server. Like
+// CloseResultSet, the command is sent together with the next command.
+func (c *mapiConn) ReleasePrepared(execId int) {
+	c.sendLater(fmt.Sprintf("Xrelease %d", execId))
+}
+
+func (c *mapiConn) sendLater(operation string) {
+	if c.State == mapi_STATE_READY {
+		c.deferred = append(c.deferred, operation)
+	}
+}
+
 func (c *mapiConn) SetSizeHeader(enable bool) (string, error) {
 	var sizeheader int
 	if enable {
@@ -145,12 +269,20 @@ func (c *mapiConn) SetSizeHeader(enable bool) (string, error) {
 	}
 	// We don't need an else here, the sizehandler is initialized to 0 by default
 	cmd := fmt.Sprintf("Xsizeheader %d", sizeheader)
-	return c.cmd(cmd)
+	r, err := c.cmd(cmd)
+	if err == nil {
+		c.sizeHeader = enable
+	}
+	return r, err
 }
 
 func (c *mapiConn) SetReplySize(size int) (string, error) {
 	cmd := fmt.Sprintf("Xreply_size %d", size)
-	return c.cmd(cmd)
+	r, err := c.cmd(cmd)
+	if err == nil {
+		c.replySize = size
+	}
+	return r, err
 }
 
 func (c *mapiConn) SetAutoCommit(enable bool) (string, error) {
@@ -159,48 +291,64 @@ func (c *mapiConn) SetAutoCommit(enable bool) (string, error) {
 		autoCommit = 1
 	}
 	cmd := fmt.Sprintf("Xauto_commit %d", autoCommit)
-	return c.cmd(cmd)
+	r, err := c.cmd(cmd)
+	if err == nil {
+		c.autoCommit = enable
+		c.transaction = !enable
+	}
+	return r, err
 }
 
 func (c *mapiConn) SetServerTimezone(timezone *time.Location) error {
-	tz, err := time.LoadLocation(timezone.String())
-	if err != nil {
-		return err
+	if timezone == nil {
+		return fmt.Errorf("mapi: timezone is not set")
 	}
-	if tz.String() != c.timezone.String() {
-		// The data we use does not matter, we are only interested in the
-		// offset of the timezone
-		tm, err := time.ParseInLocation("2006-01-02", "2024-02-29", timezone)
-		if err != nil {
-			return err
-		}
-		_, offset := tm.Zone()
-
-		hours := int(offset / 3600)
-		remaining := offset - 3600 * hours
-		minutes := int(remaining / 60)
-		// Go does not have an absolute value function for int
-		if minutes < 0 {
-			minutes = -1 * minutes
-		}
-		query := fmt.Sprintf("SET TIME ZONE INTERVAL '%+03d:%02d' HOUR TO MINUTE;", hours, minutes)
-		_, err = c.Execute(query)
+	if timezone.String() != c.timezone.String() {
+		return c.setTimezone(timezone)
+	}
+	return nil
+}
+
+func (c *mapiConn) setTimezone(timezone *time.Location) error {
+	offset := timezoneOffset(timezone)
+
+	hours := int(offset / 3600)
+	remaining := offset - 3600 * hours
+	minutes := int(remaining / 60)
+	// Go does not have an absolute value function for int
+	if minutes < 0 {
+		minutes = -1 * minutes
+	}
+	query := fmt.Sprintf("SET TIME ZONE INTERVAL '%+03d:%02d' HOUR TO MINUTE;", hours, minutes)
+	if _, err := c.Execute(query); err != nil {
 		return err
 	}
+	c.timezone = timezone
 	return nil
 }
 
+func (c *mapiConn) SetSchema(schema string) error {
+	query := fmt.Sprintf("SET SCHEMA \"%s\"", strings.ReplaceAll(schema, "\"", "\"\""))
+	_, err := c.Execute(query)
+	return err
+}
+
+func (c *mapiConn) SetUploader(uploader Uploader) {
+	c.Uploader = uploader
+}
+
+func (c *mapiConn) SetDownloader(downloader Downloader) {
+	c.Downloader = downloader
+}
+
 // Cmd sends a MAPI command to MonetDB.
 func (c *mapiConn) cmd(operation string) (string, error) {
-	if c.State != mapi_STATE_READY {
-		return "", fmt.Errorf("mapi: database is not connected")
-	}
-
-	if err := c.putBlock([]byte(operation)); err != nil {
+	if err := c.send(operation); err != nil {
 		return "", err
 	}
 
-	r, err := c.getBlock()
+	r, err := c.getResponse()
+	c.setRunning(false)
 	if err != nil {
 		return "", err
 	}
@@ -212,125 +360,301 @@ func (c *mapiConn) cmd(operation string) (string, error) {
 	} else if strings.HasPrefix(resp, mapi_MSG_OK) {
 		return strings.TrimSpace(resp[3:]), nil
 
-	} else if resp == mapi_MSG_MORE {
-		// tell server it isn't going to get more
-		return c.cmd("")
-
 	} else if strings.HasPrefix(resp, mapi_MSG_Q) || strings.HasPrefix(resp, mapi_MSG_HEADER) || strings.HasPrefix(resp, mapi_MSG_TUPLE) {
 		return resp, nil
 
 	} else if strings.HasPrefix(resp, mapi_MSG_ERROR) {
-		return "", fmt.Errorf("mapi: operational error: %s", resp[1:])
+		return "", errorOf(strings.Split(resp, "\n"))
 
 	} else {
 		return "", fmt.Errorf("mapi: unknown state: %s", resp)
 	}
 }
 
+// stream sends a MAPI command and returns the response, which is read while
+// it is processed
+func (c *mapiConn) stream(operation string) (*responseReader, error) {
+	if err := c.send(operation); err != nil {
+		return nil, err
+	}
+	c.pending = c.newResponse()
+	return c.pending, nil
+}
+
+// send sends a MAPI command. When the response of the previous command is
+// still being read, the rest of it is moved to memory first. The deferred
+// commands, and the query for the session id, are sent right before the
+// command, without waiting for their responses, which are read after the
+// command is sent.
+func (c *mapiConn) send(operation string) error {
+	if c.State != mapi_STATE_READY {
+		return fmt.Errorf("mapi: database is not connected")
+	}
+	if c.pending != nil {
+		if err := c.pending.buffer(); err != nil {
+			return err
+		}
+	}
+	c.startStatement(operation)
+	c.trackCommand(operation)
+
+	deferred := c.deferred
+	c.deferred = nil
+	if c.askSessionId {
+		deferred = append(deferred, mapi_SESSION_ID_QUERY)
+		c.askSessionId = false
+	}
+
+	c.mu.Lock()
+	c.running = true
+	var err error
+	for _, command := range deferred {
+		if err = c.putBlock([]byte(command)); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = c.putBlock([]byte(operation))
+	}
+	c.mu.Unlock()
+	// An error of a housekeeping command does not concern the command, the
+	// result set may already be closed by the server
+	for i := 0; i < len(deferred) && err == nil; i++ {
+		var resp []byte
+		resp, err = c.getBlock()
+		if err == nil && deferred[i] == mapi_SESSION_ID_QUERY {
+			c.setSessionId(resp)
+		}
+	}
+	if err != nil {
+		c.setRunning(false)
+	}
+	return err
+}
+
+func (c *mapiConn) setRunning(running bool) {
+	c.mu.Lock()
+	c.running = running
+	c.mu.Unlock()
+}
+
 // Connect starts a MAPI connection to MonetDB server.
 func (c *mapiConn) Connect() error {
-	if c.conn != nil {
-		c.conn.Close()
-		c.conn = nil
+	return c.ConnectContext(context.Background())
+}
+
+// ConnectContext starts a MAPI connection to MonetDB server. The context
+// and the ConnectTimeout both limit the time it takes to dial and log in.
+// When the timeout expires, a *TimeoutError is returned.
+func (c *mapiConn) ConnectContext(ctx context.Context) error {
+	if c.ConnectTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.ConnectTimeout)
+		defer cancel()
 	}
 
-	addr := fmt.Sprintf("%s:%d", c.Hostname, c.Port)
-	raddr, err := net.ResolveTCPAddr("tcp", addr)
+	err := c.connect(ctx)
+	if err == nil && c.Language == "sql" {
+		stop := watchContext(ctx, c.conn)
+		// Without out-of-band interrupts a running statement can only be
+		// stopped from another connection, which needs to know the id of
+		// this session.
+		c.askSessionId = c.StopSession && !c.canSendOOB()
+		err = c.configureSession()
+		stop()
+	}
 	if err != nil {
+		c.Disconnect()
+		if ctx.Err() == context.DeadlineExceeded {
+			return &TimeoutError{Op: "connect", Err: ctx.Err()}
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		return err
 	}
+	return nil
+}
 
-	conn, err := net.DialTCP("tcp", nil, raddr)
-	if err != nil {
-		return err
+// connect opens the network connection and logs in. Redirects to another
+// server are followed until the login succeeds, up to MaxRedirects times.
+func (c *mapiConn) connect(ctx context.Context) error {
+	endpoint := c.Config.endpoint()
+	redirects := 0
+	for {
+		if c.conn != nil {
+			c.conn.Close()
+			c.conn = nil
+		}
+
+		conn, err := c.dial(ctx, endpoint)
+		if err != nil {
+			return err
+		}
+		c.conn = conn
+		c.endpoint = endpoint
+		c.broken = false
+		c.deferred = nil
+		c.sessionId = -1
+		if c.Tracer != nil {
+			c.traceId = c.Tracer.newConnection()
+			c.traced = c.traced[:0]
+		}
+
+		next, err := c.loginWithRedirects(ctx, &redirects)
+		if err != nil || next == nil {
+			return err
+		}
+		endpoint = *next
 	}
+}
 
-	conn.SetKeepAlive(false)
-	conn.SetNoDelay(true)
-	c.conn = conn
+// loginWithRedirects logs in on the current connection. A merovingian
+// redirect restarts the login on the same connection. When the server
+// redirects to another endpoint, that endpoint is returned.
+func (c *mapiConn) loginWithRedirects(ctx context.Context, redirects *int) (*Endpoint, error) {
+	stop := watchContext(ctx, c.conn)
+	defer stop()
+
+	for {
+		r, err := c.tryLogin()
+		if err != nil || r == nil {
+			return nil, err
+		}
+
+		*redirects++
+		if *redirects > c.MaxRedirects {
+			return nil, fmt.Errorf("mapi: maximal number of redirects reached (%d)", c.MaxRedirects)
+		}
+		if !r.merovingian {
+			return r.parseEndpoint(c.endpoint)
+		}
+	}
+}
+
+// dial opens the network connection to the server. When a Unix domain socket
+// is configured it is tried first. Like mclient, we fall back to TCP when the
+// socket file is absent or nobody is listening on it. Encrypted connections
+// always use TCP. With a Replay the transcript takes the place of the
+// server.
+func (c *mapiConn) dial(ctx context.Context, e Endpoint) (net.Conn, error) {
+	if c.Replay != nil {
+		return c.Replay.dial()
+	}
+	if e.TLS {
+		conn, err := c.dialTCP(ctx, e)
+		if err != nil {
+			return nil, err
+		}
+		return c.startTLS(ctx, conn, e.Hostname)
+	}
+	if e.Socket != "" {
+		conn, err := c.dialUnix(ctx, e)
+		if err == nil || e.Hostname == "" {
+			return conn, err
+		}
+		if !errors.Is(err, fs.ErrNotExist) && !errors.Is(err, syscall.ECONNREFUSED) {
+			return nil, err
+		}
+	}
+	return c.dialTCP(ctx, e)
+}
 
-	err = c.login()
+func (c *mapiConn) dialUnix(ctx context.Context, e Endpoint) (net.Conn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", e.Socket)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	// On a Unix domain socket the server expects a single byte before the
+	// login challenge is sent. The value "0" means that no file descriptor
+	// is passed along with it.
+	if _, err := conn.Write([]byte("0")); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
 }
 
-// login starts the login sequence
-func (c *mapiConn) login() error {
-	return c.tryLogin(0)
+func (c *mapiConn) dialTCP(ctx context.Context, e Endpoint) (net.Conn, error) {
+	addr := fmt.Sprintf("%s:%d", e.Hostname, e.Port)
+	var d net.Dialer
+	nc, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := nc.(*net.TCPConn)
+	conn.SetKeepAlive(false)
+	conn.SetNoDelay(true)
+	return conn, nil
 }
 
-// tryLogin performs the login activity
-func (c *mapiConn) tryLogin(iteration int) error {
+// tryLogin performs the login activity. When the server redirects the
+// client, the redirect is returned.
+func (c *mapiConn) tryLogin() (*redirect, error) {
 	challenge, err := c.getBlock()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	response, err := c.challengeResponse(challenge)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	c.putBlock([]byte(response))
+	if err := c.putBlock([]byte(response)); err != nil {
+		return nil, err
+	}
 
 	bprompt, err := c.getBlock()
 	if err != nil {
-		return nil
+		return nil, err
 	}
 
-	prompt := strings.TrimSpace(string(bprompt))
-	if len(prompt) == 0 {
-		// Empty response, server is happy
+	// The server may send several redirects, the first one is used
+	var r *redirect
+	lines := strings.Split(strings.TrimSpace(string(bprompt)), "\n")
+	for _, line := range lines {
+		if len(line) == 0 {
+			// Empty response, server is happy
 
-	} else if prompt == mapi_MSG_OK {
-		// pass
+		} else if line == mapi_MSG_OK {
+			// pass
 
-	} else if strings.HasPrefix(prompt, mapi_MSG_INFO) {
-		// TODO log info
+		} else if strings.HasPrefix(line, mapi_MSG_INFO) {
+			c.message(line)
 
-	} else if strings.HasPrefix(prompt, mapi_MSG_ERROR) {
-		// TODO log error
-		return fmt.Errorf("mapi: database error: %s", prompt[1:])
+		} else if strings.HasPrefix(line, mapi_MSG_ERROR) {
+			return nil, errorOf(lines)
 
-	} else if strings.HasPrefix(prompt, mapi_MSG_REDIRECT) {
-		t := strings.Split(prompt, " ")
-		r := strings.Split(t[0][1:], ":")
-
-		if r[1] == "merovingian" {
-			// restart auth
-			if iteration <= 10 {
-				c.tryLogin(iteration + 1)
-			} else {
-				return fmt.Errorf("mapi: maximal number of redirects reached (10)")
+		} else if strings.HasPrefix(line, mapi_MSG_REDIRECT) {
+			if r == nil {
+				r, err = parseRedirect(line[1:])
+				if err != nil {
+					return nil, err
+				}
 			}
-
-		} else if r[1] == "monetdb" {
-			c.Hostname = r[2][2:]
-			t = strings.Split(r[3], "/")
-			port, _ := strconv.ParseInt(t[0], 10, 32)
-			c.Port = int(port)
-			c.Database = t[1]
-			c.conn.Close()
-			c.Connect()
-
 		} else {
-			return fmt.Errorf("mapi: unknown redirect: %s", prompt)
+			return nil, fmt.Errorf("mapi: unknown state: %s", line)
 		}
-	} else {
-		return fmt.Errorf("mapi: unknown state: %s", prompt)
+	}
+	if r != nil {
+		return r, nil
 	}
 
 	c.State = mapi_STATE_READY
 
-	return nil
+	return nil, nil
 }
 
 // challengeResponse produces a response given a challenge
 func (c *mapiConn) challengeResponse(challenge []byte) (string, error) {
 	t := strings.Split(string(challenge), ":")
+	if len(t) < 6 {
+		return "", fmt.Errorf("mapi: invalid challenge: %s", challenge)
+	}
 	salt := t[0]
 	protocol := t[2]
 	hashes := t[3]
@@ -340,111 +664,57 @@ func (c *mapiConn) challengeResponse(challenge []byte) (string, error) {
 		return "", fmt.Errorf("mapi: we only speak protocol v9")
 	}
 
-	var h hash.Hash
-	if algo == "SHA512" {
-		h = crypto.SHA512.New()
-	} else {
-		// TODO support more algorithm
-		return "", fmt.Errorf("mapi: unsupported algorithm: %s", algo)
-	}
-	io.WriteString(h, c.Password)
-	p := fmt.Sprintf("%x", h.Sum(nil))
-
-	shashes := "," + hashes + ","
-	var pwhash string
-	if strings.Contains(shashes, ",SHA1,") {
-		h = crypto.SHA1.New()
-		io.WriteString(h, p)
-		io.WriteString(h, salt)
-		pwhash = fmt.Sprintf("{SHA1}%x", h.Sum(nil))
-
-	} else if strings.Contains(shashes, ",MD5,") {
-		h = crypto.MD5.New()
-		io.WriteString(h, p)
-		io.WriteString(h, salt)
-		pwhash = fmt.Sprintf("{MD5}%x", h.Sum(nil))
-
-	} else {
-		return "", fmt.Errorf("mapi: unsupported hash algorithm required for login %s", hashes)
+	p, err := c.passwordDigest(algo)
+	if err != nil {
+		return "", err
 	}
 
-	r := fmt.Sprintf("BIG:%s:%s:%s:%s:", c.Username, pwhash, c.Language, c.Database)
-	return r, nil
-}
-
-// getBlock retrieves a block of message
-func (c *mapiConn) getBlock() ([]byte, error) {
-	r := new(bytes.Buffer)
-
-	last := 0
-	for last != 1 {
-		flag, err := c.getBytes(2)
-		if err != nil {
-			return nil, err
-		}
-
-		var unpacked uint16
-		buf := bytes.NewBuffer(flag)
-		err = binary.Read(buf, binary.LittleEndian, &unpacked)
-		if err != nil {
-			return nil, err
-		}
-
-		length := unpacked >> 1
-		last = int(unpacked & 1)
+	pwhash, err := saltedHash(hashes, p, salt)
+	if err != nil {
+		return "", err
+	}
 
-		d, err := c.getBytes(int(length))
-		if err != nil {
-			return nil, err
+	// The fields after the algorithm are the options the server supports
+	c.oobIntr = false
+	for _, option := range t[6:] {
+		if option == "OOBINTR=1" {
+			c.oobIntr = true
 		}
-
-		r.Write(d)
 	}
+	options := c.handshakeOptions(c.parseHandshakeLevel(t[6:]))
 
-	return r.Bytes(), nil
+	// FILETRANS tells the server that we can handle file transfer requests
+	r := fmt.Sprintf("BIG:%s:%s:%s:%s:FILETRANS:", c.Username, pwhash, c.Language, c.endpoint.Database)
+	if options != "" {
+		r += options + ":"
+	}
+	return r, nil
 }
 
-// getBytes reads the given amount of bytes
-func (c *mapiConn) getBytes(count int) ([]byte, error) {
-	r := make([]byte, count)
-	b := make([]byte, count)
-
-	read := 0
-	for read < count {
-		n, err := c.conn.Read(b)
-		if err != nil {
-			return nil, err
-		}
-		copy(r[read:], b[:n])
-		read += n
+// getBlock retrieves a complete message
+func (c *mapiConn) getBlock() ([]byte, error) {
+	var r bytes.Buffer
+	if _, err := r.ReadFrom(&messageReader{c: c}); err != nil {
+		return nil, err
 	}
-
-	return r, nil
+	return r.Bytes(), nil
 }
 
 // putBlock sends the given data as one or more blocks
 func (c *mapiConn) putBlock(b []byte) error {
+	c.traceSent(b)
 	pos := 0
-	last := 0
-	for last != 1 {
+	last := false
+	for !last {
 		end := pos + mapi_MAX_PACKAGE_LENGTH
 		if end > len(b) {
 			end = len(b)
 		}
 		data := b[pos:end]
 		length := len(data)
-		if length < mapi_MAX_PACKAGE_LENGTH {
-			last = 1
-		}
+		last = length < mapi_MAX_PACKAGE_LENGTH
 
-		packed := uint16((length << 1) + last)
-		flag := new(bytes.Buffer)
-		binary.Write(flag, binary.LittleEndian, packed)
-
-		if _, err := c.conn.Write(flag.Bytes()); err != nil {
-			return err
-		}
-		if _, err := c.conn.Write(data); err != nil {
+		if err := c.writeBlock(data, last); err != nil {
 			return err
 		}
 
@@ -453,3 +723,26 @@ func (c *mapiConn) putBlock(b []byte) error {
 
 	return nil
 }
+
+// writeBlock sends a single block. The last block of a message is marked
+// with the last flag.
+func (c *mapiConn) writeBlock(data []byte, last bool) error {
+	packed := uint16(len(data) << 1)
+	if last {
+		packed += 1
+	}
+	flag := new(bytes.Buffer)
+	binary.Write(flag, binary.LittleEndian, packed)
+
+	c.conn.SetWriteDeadline(c.ioDeadline(c.WriteTimeout))
+	if _, err := c.conn.Write(flag.Bytes()); err != nil {
+		return c.ioError("write", err)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	if _, err := c.conn.Write(data); err != nil {
+		return c.ioError("write", err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapi
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+func TestDial(t *testing.T) {
+	t.Run("Verify dial sends handshake byte on unix socket", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), ".s.monetdb.50000")
+		l, err := net.Listen("unix", path)
+		if err != nil {
+			t.Skip("unix domain sockets not available")
+		}
+		defer l.Close()
+
+		received := make(chan []byte, 1)
+		go func() {
+			conn, err := l.Accept()
+			if err != nil {
+				received <- nil
+				return
+			}
+			defer conn.Close()
+			b := make([]byte, 1)
+			conn.Read(b)
+			received <- b
+		}()
+
+		c := mapiConn{Config: Config{Socket: path}}
+		conn, err := c.dial(context.Background(), c.Config.endpoint())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		if b := <-received; string(b) != "0" {
+			t.Errorf("Unexpected handshake byte: %v", b)
+		}
+	})
+
+	t.Run("Verify dial falls back to tcp when socket is absent", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close()
+		go func() {
+			conn, err := l.Accept()
+			if err == nil {
+				conn.Close()
+			}
+		}()
+
+		c := mapiConn{Config: Config{
+			Hostname: "127.0.0.1",
+			Port:     l.Addr().(*net.TCPAddr).Port,
+			Socket:   filepath.Join(t.TempDir(), ".s.monetdb.50000"),
+		}}
+		conn, err := c.dial(context.Background(), c.Config.endpoint())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	})
+}
@@ -0,0 +1,68 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapi
+
+import (
+	"strings"
+)
+
+// Message is an info or warning message that the server sends along with a
+// response, for example about a deprecated feature or an implicit cast.
+type Message struct {
+	// Text is the message without the leading '#'
+	Text string
+	// Query is the statement that caused the message. It is empty for the
+	// messages that are sent during the login.
+	Query string
+}
+
+// MessageHandler receives the messages of the server while they arrive. It
+// is called from the goroutine that executes the statement, so it must not
+// use the connection itself.
+type MessageHandler interface {
+	Message(msg Message)
+}
+
+// MessageHandlerFunc is an adapter to use an ordinary function as
+// MessageHandler
+type MessageHandlerFunc func(msg Message)
+
+func (f MessageHandlerFunc) Message(msg Message) {
+	f(msg)
+}
+
+func (c *mapiConn) SetMessageHandler(handler MessageHandler) {
+	c.MessageHandler = handler
+}
+
+// Messages returns the messages of the last statement that was executed on
+// the connection. Before the first statement these are the messages of the
+// login.
+func (c *mapiConn) Messages() []Message {
+	return append([]Message(nil), c.messages...)
+}
+
+// startStatement starts collecting the messages of a new statement. The
+// other commands, like fetching the next block of rows, belong to the
+// current statement.
+func (c *mapiConn) startStatement(operation string) {
+	if !strings.HasPrefix(operation, "s") {
+		return
+	}
+	c.statement = strings.TrimSuffix(operation[1:], ";")
+	c.messages = nil
+}
+
+// message collects an info line of the server
+func (c *mapiConn) message(line string) {
+	msg := Message{
+		Text:  strings.TrimSpace(strings.TrimPrefix(line, mapi_MSG_INFO)),
+		Query: c.statement,
+	}
+	c.messages = append(c.messages, msg)
+	if c.MessageHandler != nil {
+		c.MessageHandler.Message(msg)
+	}
+}
@@ -0,0 +1,83 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapi
+
+import (
+	"context"
+	"io"
+	"testing"
+)
+
+func TestMessages(t *testing.T) {
+	t.Run("Verify messages of the login", func(t *testing.T) {
+		port, _ := newLoginServer(t, "#Welcome to MonetDB\n")
+		c := newRedirectTestConn(port)
+		var received []Message
+		c.MessageHandler = MessageHandlerFunc(func(msg Message) {
+			received = append(received, msg)
+		})
+		if err := c.connect(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		defer c.Disconnect()
+
+		if len(received) != 1 || received[0] != (Message{Text: "Welcome to MonetDB"}) {
+			t.Errorf("Unexpected messages: %v", received)
+		}
+		if messages := c.Messages(); len(messages) != 1 {
+			t.Errorf("Unexpected messages: %v", messages)
+		}
+	})
+
+	t.Run("Verify messages of a statement", func(t *testing.T) {
+		c, server := newTestConn(t)
+		var received []Message
+		c.MessageHandler = MessageHandlerFunc(func(msg Message) {
+			received = append(received, msg)
+		})
+		go func() {
+			readMessage(server)
+			writeMessage(server, "#first warning\n&2 1 -1\n#second warning\n")
+			readMessage(server)
+			writeMessage(server, tableResponse(1, 2, 0, 1)+"#during the rows\n")
+			readMessage(server)
+			writeMessage(server, tableResponse(1, 2, 1, 1))
+		}()
+
+		resp, err := c.Execute("INSERT INTO t VALUES (1)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp != "&2 1 -1\n" {
+			t.Errorf("Unexpected response: %q", resp)
+		}
+		expected := []Message{
+			{Text: "first warning", Query: "INSERT INTO t VALUES (1)"},
+			{Text: "second warning", Query: "INSERT INTO t VALUES (1)"},
+		}
+		if messages := c.Messages(); len(messages) != 2 || messages[0] != expected[0] || messages[1] != expected[1] {
+			t.Errorf("Unexpected messages: %v", messages)
+		}
+
+		q := NewQuery(c, "SELECT * FROM t")
+		if err := q.OpenQuery(); err != nil {
+			t.Fatal(err)
+		}
+		for {
+			if _, err := q.NextRow(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatal(err)
+			}
+		}
+		// Fetching the next block belongs to the same statement
+		if messages := c.Messages(); len(messages) != 1 || messages[0].Query != "SELECT * FROM t" {
+			t.Errorf("Unexpected messages: %v", messages)
+		}
+		if len(received) != 3 {
+			t.Errorf("Unexpected messages: %v", received)
+		}
+	})
+}
@@ -0,0 +1,186 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapi
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+)
+
+// UUID is a value of a UUID column
+type UUID [16]byte
+
+// ParseUUID parses a uuid like 26d7a80b-7538-4682-a49a-9d0f9676b765, the
+// hyphens are optional
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	digits := strings.ReplaceAll(strings.TrimSpace(s), "-", "")
+	if len(digits) != 2*len(u) {
+		return u, fmt.Errorf("mapi: invalid uuid: %q", s)
+	}
+	if _, err := hex.Decode(u[:], []byte(digits)); err != nil {
+		return u, fmt.Errorf("mapi: invalid uuid: %q", s)
+	}
+	return u, nil
+}
+
+// String returns the uuid in the canonical form, with hyphens
+func (u UUID) String() string {
+	s := hex.EncodeToString(u[:])
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+}
+
+// Scan implements the sql.Scanner interface. It accepts the uuids of the
+// driver and strings.
+func (u *UUID) Scan(src interface{}) error {
+	var err error
+	switch v := src.(type) {
+	case UUID:
+		*u = v
+	case string:
+		*u, err = ParseUUID(v)
+	case []byte:
+		*u, err = ParseUUID(string(v))
+	case nil:
+		err = fmt.Errorf("mapi: cannot scan NULL into a UUID")
+	default:
+		err = fmt.Errorf("mapi: cannot scan %T into a UUID", src)
+	}
+	return err
+}
+
+// Value implements the driver.Valuer interface
+func (u UUID) Value() (driver.Value, error) {
+	return u.String(), nil
+}
+
+// JSON scans a value of a JSON column into V with json.Unmarshal, and
+// passes V as an argument with json.Marshal, like
+//
+//	var doc Document
+//	err := row.Scan(&mapi.JSON{V: &doc})
+type JSON struct {
+	V interface{}
+}
+
+// Scan implements the sql.Scanner interface. It accepts the json values
+// of the driver and strings.
+func (j *JSON) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case json.RawMessage:
+		return json.Unmarshal(v, j.V)
+	case []byte:
+		return json.Unmarshal(v, j.V)
+	case string:
+		return json.Unmarshal([]byte(v), j.V)
+	case nil:
+		return fmt.Errorf("mapi: cannot scan NULL into JSON")
+	default:
+		return fmt.Errorf("mapi: cannot scan %T into JSON", src)
+	}
+}
+
+// Value implements the driver.Valuer interface
+func (j JSON) Value() (driver.Value, error) {
+	b, err := json.Marshal(j.V)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// ConvertNative converts a value of a uuid, inet, url or json column, which
+// the result set holds as a string, to a UUID, a netip.Prefix, a *url.URL
+// or a json.RawMessage. The values of other columns are returned as is.
+func ConvertNative(value Value, columnType string) (Value, error) {
+	s, ok := value.(string)
+	if !ok {
+		return value, nil
+	}
+	switch columnType {
+	case MDB_UUID:
+		return ParseUUID(s)
+	case MDB_INET:
+		return parseInet(s)
+	case MDB_URL:
+		return url.Parse(s)
+	case MDB_JSON:
+		return json.RawMessage(s), nil
+	}
+	return value, nil
+}
+
+// parseInet parses an address with an optional netmask, an address without
+// one is a prefix with all its bits
+func parseInet(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return p, fmt.Errorf("mapi: invalid inet: %q", s)
+		}
+		return p, nil
+	}
+	a, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("mapi: invalid inet: %q", s)
+	}
+	return netip.PrefixFrom(a, a.BitLen()), nil
+}
+
+// toNativeString renders a uuid, inet, url or json value as a string cast
+// to the type of the column
+func toNativeString(v Value) (string, error) {
+	var s, columnType string
+	switch val := v.(type) {
+	case UUID:
+		s, columnType = val.String(), MDB_UUID
+	case netip.Prefix:
+		s, columnType = val.String(), MDB_INET
+		if val.IsValid() && val.Bits() == val.Addr().BitLen() {
+			s = val.Addr().String()
+		}
+	case netip.Addr:
+		s, columnType = val.String(), MDB_INET
+	case net.IP:
+		if val == nil {
+			return "NULL", nil
+		}
+		s, columnType = val.String(), MDB_INET
+	case *net.IPNet:
+		if val == nil {
+			return "NULL", nil
+		}
+		s, columnType = val.String(), MDB_INET
+	case *url.URL:
+		if val == nil {
+			return "NULL", nil
+		}
+		s, columnType = val.String(), MDB_URL
+	case json.RawMessage:
+		if val == nil {
+			return "NULL", nil
+		}
+		s, columnType = string(val), MDB_JSON
+	case JSON:
+		b, err := json.Marshal(val.V)
+		if err != nil {
+			return "", err
+		}
+		s, columnType = string(b), MDB_JSON
+	default:
+		return "", fmt.Errorf("mapi: unsupported type")
+	}
+	q, err := toQuotedString(s)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("CAST(%s AS %s)", q, columnType), nil
+}
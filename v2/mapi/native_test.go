@@ -0,0 +1,120 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapi
+
+import (
+	"encoding/json"
+	"net"
+	"net/netip"
+	"net/url"
+	"testing"
+)
+
+func TestNativeTypes(t *testing.T) {
+	t.Run("Verify uuid round trip", func(t *testing.T) {
+		const text = "26d7a80b-7538-4682-a49a-9d0f9676b765"
+		v, err := convertToGo(`"`+text+`"`, MDB_UUID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, err := ConvertNative(v, MDB_UUID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.(UUID).String() != text || u.(UUID)[0] != 0x26 {
+			t.Errorf("Unexpected uuid: %v", u)
+		}
+		literal, _ := ConvertToMonet(u)
+		if literal != "CAST('"+text+"' AS uuid)" {
+			t.Errorf("Unexpected literal: %s", literal)
+		}
+		var scanned UUID
+		if err := scanned.Scan([]byte("26D7A80B75384682A49A9D0F9676B765")); err != nil || scanned != u {
+			t.Errorf("Unexpected scanned uuid: %v, %v", scanned, err)
+		}
+		if _, err := ParseUUID("26d7a80b-7538"); err == nil {
+			t.Errorf("Expected error for short uuid")
+		}
+	})
+
+	t.Run("Verify inet values", func(t *testing.T) {
+		tests := []struct {
+			text    string
+			prefix  netip.Prefix
+			literal string
+		}{
+			{"192.168.0.1", netip.MustParsePrefix("192.168.0.1/32"), "CAST('192.168.0.1' AS inet)"},
+			{"10.1.0.0/16", netip.MustParsePrefix("10.1.0.0/16"), "CAST('10.1.0.0/16' AS inet)"},
+			{"::1", netip.MustParsePrefix("::1/128"), "CAST('::1' AS inet)"},
+		}
+		for _, tt := range tests {
+			v, err := ConvertNative(tt.text, MDB_INET)
+			if err != nil || v != tt.prefix {
+				t.Errorf("Unexpected prefix for %s: %v, %v", tt.text, v, err)
+			}
+			literal, _ := ConvertToMonet(tt.prefix)
+			if literal != tt.literal {
+				t.Errorf("Unexpected literal for %s: %s", tt.text, literal)
+			}
+		}
+		if _, err := ConvertNative("300.1.1.1", MDB_INET); err == nil {
+			t.Errorf("Expected error for invalid inet")
+		}
+		literal, _ := ConvertToMonet(net.ParseIP("192.168.0.1"))
+		if literal != "CAST('192.168.0.1' AS inet)" {
+			t.Errorf("Unexpected literal: %s", literal)
+		}
+	})
+
+	t.Run("Verify url and json values", func(t *testing.T) {
+		v, err := ConvertNative("https://www.monetdb.org/it's", MDB_URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u := v.(*url.URL); u.Host != "www.monetdb.org" {
+			t.Errorf("Unexpected url: %v", u)
+		}
+		literal, _ := ConvertToMonet(v)
+		if literal != `CAST('https://www.monetdb.org/it\'s' AS url)` {
+			t.Errorf("Unexpected literal: %s", literal)
+		}
+
+		v, err = convertToGo(`"{\"a\": [1, 2]}"`, MDB_JSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := ConvertNative(v, MDB_JSON)
+		if err != nil || string(raw.(json.RawMessage)) != `{"a": [1, 2]}` {
+			t.Errorf("Unexpected json: %v, %v", raw, err)
+		}
+		var doc struct{ A []int }
+		if err := (&JSON{V: &doc}).Scan(raw); err != nil || len(doc.A) != 2 {
+			t.Errorf("Unexpected document: %v, %v", doc, err)
+		}
+		literal, _ = ConvertToMonet(JSON{V: doc})
+		if literal != `CAST('{"A":[1,2]}' AS json)` {
+			t.Errorf("Unexpected literal: %s", literal)
+		}
+	})
+
+	t.Run("Verify native parameters", func(t *testing.T) {
+		u, _ := ParseUUID("26d7a80b-7538-4682-a49a-9d0f9676b765")
+		literal, _ := ConvertParameter(u, Parameter{ColumnType: MDB_UUID})
+		if literal != "CAST('26d7a80b-7538-4682-a49a-9d0f9676b765' AS uuid)" {
+			t.Errorf("Unexpected literal: %s", literal)
+		}
+		literal, _ = ConvertParameter(json.RawMessage(`[]`), Parameter{ColumnType: MDB_CLOB})
+		if literal != "CAST(CAST('[]' AS json) AS clob)" {
+			t.Errorf("Unexpected literal: %s", literal)
+		}
+	})
+
+	t.Run("Verify other columns are unchanged", func(t *testing.T) {
+		v, err := ConvertNative("text", MDB_VARCHAR)
+		if err != nil || v != "text" {
+			t.Errorf("Unexpected value: %v, %v", v, err)
+		}
+	})
+}
@@ -0,0 +1,184 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parameter describes a placeholder of a prepared statement, as the server
+// reports it in the result of PREPARE. Digits and Scale are the precision
+// and the scale of a decimal, or the length of a character string.
+type Parameter struct {
+	ColumnType string
+	Digits     int
+	Scale      int
+}
+
+// SQLType returns the type of the parameter as it is written in SQL, like
+// decimal(10,2)
+func (p Parameter) SQLType() string {
+	switch p.ColumnType {
+	case MDB_DECIMAL:
+		return fmt.Sprintf("decimal(%d,%d)", p.Digits, p.Scale)
+	case MDB_CHAR, MDB_VARCHAR:
+		if p.Digits > 0 {
+			return fmt.Sprintf("%s(%d)", p.ColumnType, p.Digits)
+		}
+	case MDB_TIMESTAMPTZ:
+		return "timestamp with time zone"
+	case MDB_TIMETZ:
+		return "time with time zone"
+	case MDB_MONTH_INTERVAL:
+		return "interval month"
+	case MDB_SEC_INTERVAL:
+		return "interval second"
+	case MDB_DAY_INTERVAL:
+		return "interval day"
+	}
+	return p.ColumnType
+}
+
+// ConvertParameter converts a value to a literal for a placeholder of a
+// prepared statement. When the Go type of the value does not match the type
+// of the parameter, the literal is cast to that type, so the server converts
+// it, like a string to a date. A nil value becomes a NULL of the type of the
+// parameter. Bytes are only sent as a blob to a blob parameter, for other
+// parameters, like a varchar or json, they are the text of a string.
+func ConvertParameter(value Value, p Parameter) (string, error) {
+	if p.ColumnType == "" {
+		return ConvertToMonet(value)
+	}
+	if b, ok := value.([]byte); ok && p.ColumnType != MDB_BLOB {
+		if b == nil {
+			value = nil
+		} else {
+			value = string(b)
+		}
+	}
+	if value == nil {
+		return fmt.Sprintf("CAST(NULL AS %s)", p.SQLType()), nil
+	}
+	s, err := ConvertToMonet(value)
+	if err != nil {
+		return "", err
+	}
+	if matchesType(value, p.ColumnType) {
+		return s, nil
+	}
+	return fmt.Sprintf("CAST(%s AS %s)", s, p.SQLType()), nil
+}
+
+// matchesType reports whether the literal of a value has the SQL type, so
+// it does not need a cast
+func matchesType(value Value, columnType string) bool {
+	switch value.(type) {
+	case Decimal:
+		return columnType == MDB_DECIMAL
+	case Int128, *big.Int:
+		return columnType == MDB_HUGEINT
+	case time.Duration:
+		return columnType == MDB_SEC_INTERVAL
+	case time.Time:
+		return columnType == MDB_TIMESTAMPTZ
+	case MonthInterval:
+		return columnType == MDB_MONTH_INTERVAL
+	case UUID:
+		return columnType == MDB_UUID
+	case netip.Prefix, netip.Addr, net.IP, *net.IPNet:
+		return columnType == MDB_INET
+	case *url.URL:
+		return columnType == MDB_URL
+	case JSON, json.RawMessage:
+		return columnType == MDB_JSON
+	case []byte, Blob, io.Reader:
+		return columnType == MDB_BLOB
+	}
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.String:
+		switch columnType {
+		case MDB_CHAR, MDB_VARCHAR, MDB_CLOB:
+			return true
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		switch columnType {
+		case MDB_TINYINT, MDB_SMALLINT, MDB_INT, MDB_BIGINT, MDB_HUGEINT,
+			MDB_SERIAL, MDB_REAL, MDB_DOUBLE, MDB_FLOAT:
+			return true
+		}
+	case reflect.Float32, reflect.Float64:
+		switch columnType {
+		case MDB_REAL, MDB_DOUBLE, MDB_FLOAT:
+			return true
+		}
+	case reflect.Bool:
+		return columnType == MDB_BOOLEAN
+	}
+	return false
+}
+
+// cutParameterField cuts the first value from a row of the result of PREPARE.
+// Unlike cutField it only needs the comma, the tab may have been replaced by
+// spaces. Only the quoted names can contain a comma.
+func cutParameterField(d string) (string, string, bool) {
+	start := len(d) - len(strings.TrimLeft(d, " \t"))
+	if start < len(d) && d[start] == '"' {
+		for start++; start < len(d) && d[start] != '"'; start++ {
+			if d[start] == '\\' {
+				start++
+			}
+		}
+		if start > len(d) {
+			start = len(d)
+		}
+	}
+
+	i := strings.IndexByte(d[start:], ',')
+	if i < 0 {
+		return d, "", false
+	}
+	return d[:start+i], d[start+i+1:], true
+}
+
+// parseParameter reads a row of the result of PREPARE, which holds the type,
+// digits, scale, schema, table and column. The rows of the placeholders have
+// no column, the other rows describe the columns of the result.
+func parseParameter(line string) (Parameter, bool, error) {
+	fields := make([]string, 0, 6)
+	items := line[1 : len(line)-1]
+	for found := true; found; {
+		var value string
+		value, items, found = cutParameterField(items)
+		fields = append(fields, strings.TrimSpace(value))
+	}
+	if len(fields) < 3 || len(fields[0]) < 2 {
+		return Parameter{}, false, fmt.Errorf("mapi: invalid row in the result of PREPARE: %s", line)
+	}
+	if len(fields) >= 6 && fields[5] != "NULL" {
+		return Parameter{}, false, nil
+	}
+
+	columnType, err := strip(fields[0])
+	if err != nil {
+		return Parameter{}, false, err
+	}
+	digits, err1 := strconv.Atoi(fields[1])
+	scale, err2 := strconv.Atoi(fields[2])
+	if err1 != nil || err2 != nil {
+		return Parameter{}, false, fmt.Errorf("mapi: invalid row in the result of PREPARE: %s", line)
+	}
+	return Parameter{ColumnType: columnType.(string), Digits: digits, Scale: scale}, true, nil
+}
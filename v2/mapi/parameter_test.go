@@ -0,0 +1,70 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapi
+
+import (
+	"testing"
+)
+
+func TestConvertParameter(t *testing.T) {
+	tests := []struct {
+		value    Value
+		param    Parameter
+		expected string
+	}{
+		{int64(5), Parameter{"int", 32, 0}, "5"},
+		{int64(5), Parameter{"decimal", 10, 2}, "CAST(5 AS decimal(10,2))"},
+		{uint8(5), Parameter{"tinyint", 8, 0}, "5"},
+		{1.5, Parameter{"double", 53, 0}, "1.5"},
+		{"2021-02-03", Parameter{"date", 0, 0}, "CAST('2021-02-03' AS date)"},
+		{"text", Parameter{"varchar", 20, 0}, "'text'"},
+		{[]byte("it's"), Parameter{"varchar", 20, 0}, "'it\\'s'"},
+		{[]byte(`{"a": 1}`), Parameter{"json", 0, 0}, "CAST('{\"a\": 1}' AS json)"},
+		{[]byte{0x01, 0xff}, Parameter{"blob", 0, 0}, "CAST('01ff' AS blob)"},
+		{[]byte(nil), Parameter{"clob", 0, 0}, "CAST(NULL AS clob)"},
+		{int64(7), Parameter{"varchar", 20, 0}, "CAST(7 AS varchar(20))"},
+		{true, Parameter{"boolean", 1, 0}, "true"},
+		{nil, Parameter{"int", 32, 0}, "CAST(NULL AS int)"},
+		{nil, Parameter{"timestamptz", 7, 0}, "CAST(NULL AS timestamp with time zone)"},
+		{"x", Parameter{}, "'x'"},
+	}
+	for _, tt := range tests {
+		actual, err := ConvertParameter(tt.value, tt.param)
+		if err != nil {
+			t.Errorf("Error converting %v to %s: %v", tt.value, tt.param.SQLType(), err)
+			continue
+		}
+		if actual != tt.expected {
+			t.Errorf("Unexpected conversion of %v to %s: %s", tt.value, tt.param.SQLType(), actual)
+		}
+	}
+}
+
+func TestParseParameter(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected Parameter
+		ok       bool
+	}{
+		{"[ \"decimal\",\t10,\t2,\tNULL,\tNULL,\tNULL\t]", Parameter{"decimal", 10, 2}, true},
+		{"[ \"int\",\t32,\t0,\t\"sys\",\t\"t\",\t\"i\"\t]", Parameter{}, false},
+		{"[ \"varchar\",\t20,\t0\t]", Parameter{"varchar", 20, 0}, true},
+		{"[ \"varchar\",    16,     0,      NULL,   NULL,   NULL    ]", Parameter{"varchar", 16, 0}, true},
+	}
+	for _, tt := range tests {
+		p, ok, err := parseParameter(tt.line)
+		if err != nil {
+			t.Errorf("Error parsing %q: %v", tt.line, err)
+			continue
+		}
+		if ok != tt.ok || p != tt.expected {
+			t.Errorf("Unexpected parameter of %q: %v, %v", tt.line, p, ok)
+		}
+	}
+
+	if _, _, err := parseParameter("[ \"int\"\t]"); err == nil {
+		t.Error("Expected an error for a short row")
+	}
+}
@@ -7,6 +7,7 @@ package mapi
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"strconv"
@@ -18,6 +19,28 @@ type query struct {
 	sqlQuery string
 	resultSets []ResultSet
 	currentResultSet int
+
+	// execId is the id of the prepared statement, it is passed on to the
+	// result sets of its executions
+	execId int
+
+	// params are the placeholders of the prepared statement
+	params []Parameter
+
+	// unfinished holds the ids of the result sets of which the server still
+	// has rows that were not in the reply
+	unfinished []int
+
+	// response holds the rest of the response of an opened query. The rows
+	// of result set stream are read from it, the later result sets follow
+	// after them. The next blocks of rows are fetched into block.
+	response  Response
+	block     Response
+	stream    int
+	rowNum    int
+	blockSize int
+	row       []Value
+	err       error
 }
 
 /* The Query interface type handles the execution of a sql query, that can contain multiple
@@ -34,11 +57,18 @@ type Query interface {
 	ExecuteQuery() (string, error)
 	ExecutePreparedQuery(args []Value) (string, error)
 	ExecuteNamedQuery(names []string, args []Value) (string, error)
+	OpenQuery() error
+	OpenPreparedQuery(args []Value) error
+	OpenNamedQuery(names []string, args []Value) error
 	Result() *ResultSet
 	StoreResult(r string) error
 	FetchNext(offset int, amount int) (string, error)
+	NextRow() ([]Value, error)
 	HasNextResultSet() bool
 	NextResultSet() error
+	Close() error
+	Release()
+	Params() []Parameter
 }
 
 func NewQuery(conn MapiConn, q string) Query {
@@ -47,6 +77,8 @@ func NewQuery(conn MapiConn, q string) Query {
 		sqlQuery: q,
 		resultSets: make([]ResultSet, 0),
 		currentResultSet: -1,
+		execId: -1,
+		stream: -1,
 	}
 	return &res
 }
@@ -111,12 +143,41 @@ func getLineType(line string) LineType {
 
 func (q *query) newResultSet() {
 	r := ResultSet{}
-	r.Metadata.ExecId = -1
+	r.Metadata.ExecId = q.execId
+	if q.mapi != nil {
+		r.location = q.mapi.Location()
+	}
 	q.resultSets = append(q.resultSets, r)
-	q.currentResultSet++
+	q.currentResultSet = len(q.resultSets) - 1
 }
 
+// The response ended before the prompt
+var errIncomplete = errors.New("mapi: incomplete response")
+
 func (q *query) StoreResult(r string) error {
+	first := len(q.resultSets)
+	if _, err := q.parse(&stringResponse{s: r}, true); err != nil {
+		if err == errIncomplete {
+			return fmt.Errorf("mapi: unknown state: %s", r)
+		}
+		return err
+	}
+	// The client wants to start with the first resultset of the response,
+	// not the last one
+	q.selectFirst(first)
+	return nil
+}
+
+func (q *query) selectFirst(first int) {
+	if len(q.resultSets) > first {
+		q.currentResultSet = first
+	}
+}
+
+// parse processes the lines of a response. When storeRows is set, the tuples
+// are stored in the result set. Otherwise the parsing stops at the first
+// tuple, so the rows can be read with NextRow, and true is returned.
+func (q *query) parse(r Response, storeRows bool) (bool, error) {
 	var columnNames []string
 	var columnTypes []string
 	var displaySizes []int
@@ -124,29 +185,65 @@ func (q *query) StoreResult(r string) error {
 	var precisions []int
 	var scales []int
 	var nullOks []int
+	// prepared is set while the rows of the result of PREPARE are read
+	prepared := false
+
+	for {
+		line, err := r.ReadLine()
+		if err == io.EOF {
+			return false, errIncomplete
+		}
+		if err != nil {
+			return false, err
+		}
 
-	var addedResultSets bool
+		if line != mapi_MSG_PROMPT && strings.TrimSpace(line) == "" {
+			// A line with only whitespace carries nothing, it is not the prompt
+			continue
+		}
 
-	for _, line := range strings.Split(r, "\n") {
 		lineType := getLineType(line)
 		if lineType == INFO {
-			// TODO log
+			// The messages of a response that is read from the connection
+			// are collected by the connection
 
 		} else if lineType == QPREPARE {
 			q.newResultSet()
 
 			t := strings.Split(strings.TrimSpace(line[2:]), " ")
-			q.Result().Metadata.ExecId, _ = strconv.Atoi(t[0])
-			return nil
+			q.execId, _ = strconv.Atoi(t[0])
+			q.Result().Metadata.ExecId = q.execId
+			q.params = make([]Parameter, 0)
+			prepared = true
+
+		} else if prepared && lineType == TUPLE {
+			p, ok, err := parseParameter(line)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				q.params = append(q.params, p)
+			}
+
+		} else if prepared && lineType == HEADER {
+			// The columns of the result of PREPARE are known
 
 		} else if lineType == QTABLE {
 			q.newResultSet()
-			addedResultSets = true
 
 			t := strings.Split(strings.TrimSpace(line[2:]), " ")
 			q.Result().Metadata.QueryId, _ = strconv.Atoi(t[0])
 			q.Result().Metadata.RowCount, _ = strconv.Atoi(t[1])
 			q.Result().Metadata.ColumnCount, _ = strconv.Atoi(t[2])
+			// The number of rows in this reply is used as the size of
+			// the next blocks
+			q.blockSize = 0
+			if len(t) > 3 {
+				q.blockSize, _ = strconv.Atoi(t[3])
+			}
+			if q.blockSize < q.Result().Metadata.RowCount {
+				q.unfinished = append(q.unfinished, q.Result().Metadata.QueryId)
+			}
 
 			columnNames = make([]string, q.Result().Metadata.ColumnCount)
 			columnTypes = make([]string, q.Result().Metadata.ColumnCount)
@@ -157,9 +254,13 @@ func (q *query) StoreResult(r string) error {
 			nullOks = make([]int, q.Result().Metadata.ColumnCount)
 
 		} else if lineType == TUPLE {
+			if !storeRows {
+				r.UnreadLine()
+				return true, nil
+			}
 			v, err := q.Result().parseTuple(line)
 			if err != nil {
-				return err
+				return false, err
 			}
 			q.Result().Rows = append(q.Result().Rows, v)
 
@@ -168,7 +269,6 @@ func (q *query) StoreResult(r string) error {
 
 		} else if lineType == QSCHEMA {
 			q.newResultSet()
-			addedResultSets = true
 
 			q.Result().Metadata.Offset = 0
 			q.Result().Rows = make([][]Value, 0)
@@ -179,7 +279,6 @@ func (q *query) StoreResult(r string) error {
 		} else if lineType == QUPDATE {
 			if q.currentResultSet == -1 {
 				q.newResultSet()
-				addedResultSets = true
 			}
 
 			t := strings.Split(strings.TrimSpace(line[2:]), " ")
@@ -188,7 +287,6 @@ func (q *query) StoreResult(r string) error {
 
 		} else if lineType == QTRANS {
 			q.newResultSet()
-			addedResultSets = true
 
 			q.Result().Metadata.Offset = 0
 			q.Result().Rows = make([][]Value, 0)
@@ -248,19 +346,14 @@ func (q *query) StoreResult(r string) error {
 		} else if lineType == PROMT {
 			// At this point we processed all the data that was returned from
 			// the server. In certain cases one or more resultsets have been
-			// created, but not in every case. The client wants to start with
-			// the first resultset, not the last one. Therefore we need to set
-			// the current resultset to the first one.
-			if addedResultSets { q.currentResultSet = 0}
-			return nil
+			// created, but not in every case.
+			return false, nil
 		} else if lineType == ERROR {
-			return fmt.Errorf("mapi: database error: %s", line[1:])
+			return false, readError(line, r)
 		} else if lineType == UNKNOWN {
-			return fmt.Errorf("mapi: protocol error: %s", line)
+			return false, fmt.Errorf("mapi: protocol error: %s", line)
 		}
 	}
-
-	return fmt.Errorf("mapi: unknown state: %s", r)
 }
 
 func (q *query) FetchNext(offset int, amount int) (string, error) {
@@ -284,8 +377,40 @@ func (q *query) PrepareQuery() error {
 	return q.StoreResult(resultstring)
 }
 
+// Params returns the placeholders of a prepared statement, as they are
+// described by the server
+func (q *query) Params() []Parameter {
+	return q.params
+}
+
+// createExecString returns the EXEC statement of the prepared statement. The
+// arguments are converted to the types of the placeholders.
+func (q *query) createExecString(args []Value) (string, error) {
+	if q.params == nil {
+		return q.resultSets[q.currentResultSet].CreateExecString(args)
+	}
+	if len(args) != len(q.params) {
+		return "", fmt.Errorf("mapi: prepared statement expects %d arguments, got %d", len(q.params), len(args))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "EXEC %d (", q.execId)
+	for i, v := range args {
+		str, err := ConvertParameter(v, q.params[i])
+		if err != nil {
+			return "", fmt.Errorf("mapi: argument %d: %w", i+1, err)
+		}
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(str)
+	}
+	b.WriteString(")")
+	return b.String(), nil
+}
+
 func (q *query) ExecutePreparedQuery(args []Value) (string, error) {
-	execStr, err := q.resultSets[q.currentResultSet].CreateExecString(args)
+	execStr, err := q.createExecString(args)
 	if err != nil {
 		return "", err
 	}
@@ -325,15 +450,248 @@ func (q query) ExecuteQuery() (string, error) {
 	return q.execute(q.sqlQuery)
 }
 
-func (q query) HasNextResultSet() bool {
-	return (q.currentResultSet != -1) && (len(q.resultSets) > q.currentResultSet + 1 )
+func (q *query) OpenQuery() error {
+	return q.open(q.sqlQuery)
+}
+
+func (q *query) OpenPreparedQuery(args []Value) error {
+	execStr, err := q.createExecString(args)
+	if err != nil {
+		return err
+	}
+	return q.open(execStr)
+}
+
+func (q *query) OpenNamedQuery(names []string, args []Value) error {
+	execStr, err := q.CreateNamedString(names, args)
+	if err != nil {
+		return err
+	}
+	return q.open(execStr)
+}
+
+// open executes the query and parses the response up to the first row. The
+// rows are read with NextRow while they arrive, the rest of the response is
+// parsed by NextResultSet.
+func (q *query) open(command string) error {
+	if q.mapi == nil {
+		return fmt.Errorf("mapi: database connection is closed")
+	}
+	if err := q.Close(); err != nil {
+		return err
+	}
+
+	r, err := q.mapi.ExecuteStream(command)
+	if err != nil {
+		return err
+	}
+	q.response = r
+	q.rowNum = 0
+
+	first := len(q.resultSets)
+	rows, err := q.parse(r, false)
+	q.selectFirst(first)
+	return q.opened(rows, err)
+}
+
+// opened keeps the response when the parsing stopped at the rows of a result
+// set, otherwise the response is finished
+func (q *query) opened(rows bool, err error) error {
+	if err != nil || !rows {
+		q.response.Close()
+		q.response = nil
+		return err
+	}
+	q.stream = len(q.resultSets) - 1
+	return nil
+}
+
+// NextRow returns the next row of the current result set of an opened query,
+// or io.EOF after the last row. The returned slice is reused for the next row.
+// When the rows of a reply are read, the next block of rows is fetched.
+func (q *query) NextRow() ([]Value, error) {
+	rs := q.Result()
+	if rs == nil {
+		return nil, io.EOF
+	}
+
+	for {
+		line, err := q.nextTuple()
+		if err != nil {
+			q.Close()
+			return nil, err
+		}
+		if line != "" {
+			row, err := rs.parseRow(line, q.row)
+			if err != nil {
+				return nil, err
+			}
+			q.row = row
+			q.rowNum++
+			return row, nil
+		}
+
+		if len(rs.Schema) == 0 || q.rowNum >= rs.Metadata.RowCount {
+			return nil, io.EOF
+		}
+		if err := q.fetchBlock(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// nextTuple returns the next tuple of the current result set, or an empty
+// string when there are no more rows in the reply
+func (q *query) nextTuple() (string, error) {
+	r := q.block
+	if r == nil {
+		if q.response == nil || q.stream != q.currentResultSet {
+			return "", nil
+		}
+		r = q.response
+	}
+
+	line, err := r.ReadLine()
+	if err != nil {
+		return "", err
+	}
+	lineType := getLineType(line)
+	if lineType == TUPLE {
+		return line, nil
+	} else if lineType == ERROR {
+		return "", readError(line, r)
+	}
+
+	if r == q.block {
+		q.block = nil
+		if lineType != PROMT {
+			return "", fmt.Errorf("mapi: protocol error: %s", line)
+		}
+		return "", nil
+	}
+	// The rest of the response is parsed by NextResultSet
+	r.UnreadLine()
+	q.stream = -1
+	return "", nil
+}
+
+// fetchBlock fetches the next rows of the current result set
+func (q *query) fetchBlock() error {
+	rs := q.Result()
+	size := q.blockSize
+	if size <= 0 {
+		size = MAPI_ARRAY_SIZE
+	}
+	amount := rs.Metadata.RowCount - q.rowNum
+	if amount > size {
+		amount = size
+	}
+
+	r, err := q.mapi.FetchNextStream(rs.Metadata.QueryId, q.rowNum, amount)
+	if err != nil {
+		return err
+	}
+	q.block = r
+	rs.Metadata.Offset = q.rowNum
+
+	rows, err := q.parse(r, false)
+	if err == nil && !rows {
+		err = fmt.Errorf("mapi: no rows in block at offset %d", q.rowNum)
+	}
+	if err != nil {
+		r.Close()
+		q.block = nil
+	}
+	return err
+}
+
+func (q *query) HasNextResultSet() bool {
+	if q.currentResultSet == -1 {
+		return false
+	}
+	if len(q.resultSets) > q.currentResultSet+1 {
+		return true
+	}
+	// The next result set of an opened query follows the rows of the
+	// current one in the response. An error is returned by NextResultSet.
+	if q.response != nil && q.err == nil {
+		q.err = q.parseNext()
+	}
+	return q.err != nil || len(q.resultSets) > q.currentResultSet+1
 }
 
 func (q *query) NextResultSet() error {
-	if q.HasNextResultSet() {
-		q.currentResultSet++
-		return nil
-	} else {
+	if !q.HasNextResultSet() {
 		return io.EOF
 	}
+	if q.err != nil {
+		err := q.err
+		q.err = nil
+		return err
+	}
+	q.currentResultSet++
+	q.rowNum = 0
+	return nil
+}
+
+// parseNext skips the rows of the current result set that are not read yet,
+// and parses the response up to the rows of the next result set
+func (q *query) parseNext() error {
+	if q.block != nil {
+		if err := q.block.Close(); err != nil {
+			return err
+		}
+		q.block = nil
+	}
+	for q.stream != -1 {
+		line, err := q.response.ReadLine()
+		if err != nil {
+			return err
+		}
+		if getLineType(line) != TUPLE {
+			q.response.UnreadLine()
+			q.stream = -1
+		}
+	}
+
+	current := q.currentResultSet
+	rows, err := q.parse(q.response, false)
+	q.currentResultSet = current
+	return q.opened(rows, err)
+}
+
+// Close discards the rows of an opened query that are not read yet. The
+// result sets that the server holds are closed with the next command.
+func (q *query) Close() error {
+	if q.mapi != nil {
+		for _, id := range q.unfinished {
+			q.mapi.CloseResultSet(id)
+		}
+	}
+	q.unfinished = q.unfinished[:0]
+
+	var err error
+	if q.block != nil {
+		err = q.block.Close()
+		q.block = nil
+	}
+	if q.response != nil {
+		if cerr := q.response.Close(); err == nil {
+			err = cerr
+		}
+		q.response = nil
+	}
+	q.stream = -1
+	q.err = nil
+	return err
+}
+
+// Release deallocates the prepared statement on the server with the next
+// command
+func (q *query) Release() {
+	if q.execId != -1 && q.mapi != nil {
+		q.mapi.ReleasePrepared(q.execId)
+		q.execId = -1
+		q.params = nil
+	}
 }
@@ -0,0 +1,358 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapi
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MonetDB/MonetDB-Go/v2/mapitest"
+)
+
+// tableResponse returns the response with the first rows of a result set
+// with an int and a varchar column
+func tableResponse(queryId, rowCount, first, count int) string {
+	var b strings.Builder
+	if first == 0 {
+		fmt.Fprintf(&b, "&1 %d %d 2 %d\n", queryId, rowCount, count)
+		b.WriteString("% sys.t,\tsys.t # table_name\n")
+		b.WriteString("% id,\tname # name\n")
+		b.WriteString("% int,\tvarchar # type\n")
+		b.WriteString("% 1,\t10 # length\n")
+	} else {
+		fmt.Fprintf(&b, "&6 %d 2 %d %d\n", queryId, count, first)
+	}
+	for i := first; i < first+count; i++ {
+		fmt.Fprintf(&b, "[ %d,\t\"name%d\"\t]\n", i, i)
+	}
+	return b.String()
+}
+
+// encodeMessage returns the blocks of a message, as the server sends them
+func encodeMessage(msg string) []byte {
+	var b bytes.Buffer
+	c := mapiConn{conn: &writerConn{w: &b}}
+	c.putBlock([]byte(msg))
+	return b.Bytes()
+}
+
+// writerConn is a connection that only writes to a buffer
+type writerConn struct {
+	net.Conn
+	w io.Writer
+}
+
+func (c *writerConn) Write(p []byte) (int, error) {
+	return c.w.Write(p)
+}
+
+func (c *writerConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+func TestOpenQuery(t *testing.T) {
+	t.Run("Verify rows are fetched in blocks", func(t *testing.T) {
+		c, server := newTestConn(t)
+		commands := make(chan string, 3)
+		go func() {
+			msg, _ := readMessage(server)
+			commands <- string(msg)
+			writeMessage(server, tableResponse(3, 5, 0, 2))
+			msg, _ = readMessage(server)
+			commands <- string(msg)
+			writeMessage(server, tableResponse(3, 5, 2, 2))
+			msg, _ = readMessage(server)
+			commands <- string(msg)
+			writeMessage(server, tableResponse(3, 5, 4, 1))
+		}()
+
+		q := NewQuery(c, "SELECT * FROM t")
+		if err := q.OpenQuery(); err != nil {
+			t.Fatal(err)
+		}
+		if columns := q.Result().Columns(); len(columns) != 2 || columns[1] != "name" {
+			t.Errorf("Unexpected columns: %v", columns)
+		}
+		for i := 0; i < 5; i++ {
+			row, err := q.NextRow()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if row[0] != int32(i) || row[1] != fmt.Sprintf("name%d", i) {
+				t.Errorf("Unexpected row %d: %v", i, row)
+			}
+		}
+		if _, err := q.NextRow(); err != io.EOF {
+			t.Errorf("Expected the end of the rows, got %v", err)
+		}
+
+		expected := []string{"sSELECT * FROM t;", "Xexport 3 2 2", "Xexport 3 4 1"}
+		for _, e := range expected {
+			if cmd := <-commands; cmd != e {
+				t.Errorf("Unexpected command %q, expected %q", cmd, e)
+			}
+		}
+	})
+
+	t.Run("Verify next result set follows the rows", func(t *testing.T) {
+		c, server := newTestConn(t)
+		go func() {
+			readMessage(server)
+			writeMessage(server, "&2 1 -1\n"+tableResponse(1, 3, 0, 3)+tableResponse(2, 1, 0, 1))
+		}()
+
+		q := NewQuery(c, "INSERT INTO t VALUES (1); SELECT * FROM t; SELECT * FROM t")
+		if err := q.OpenQuery(); err != nil {
+			t.Fatal(err)
+		}
+		if q.Result().Metadata.RowCount != 1 {
+			t.Errorf("Unexpected first result set: %+v", q.Result().Metadata)
+		}
+		if _, err := q.NextRow(); err != io.EOF {
+			t.Errorf("Expected no rows, got %v", err)
+		}
+
+		if err := q.NextResultSet(); err != nil {
+			t.Fatal(err)
+		}
+		if row, err := q.NextRow(); err != nil || row[0] != int32(0) {
+			t.Errorf("Unexpected row %v: %v", row, err)
+		}
+
+		// The rows that are not read are skipped
+		if !q.HasNextResultSet() {
+			t.Fatal("Expected another result set")
+		}
+		if err := q.NextResultSet(); err != nil {
+			t.Fatal(err)
+		}
+		if q.Result().Metadata.QueryId != 2 {
+			t.Errorf("Unexpected result set: %+v", q.Result().Metadata)
+		}
+		if row, err := q.NextRow(); err != nil || row[1] != "name0" {
+			t.Errorf("Unexpected row %v: %v", row, err)
+		}
+		if q.HasNextResultSet() {
+			t.Error("Unexpected result set")
+		}
+	})
+
+	t.Run("Verify error after the rows", func(t *testing.T) {
+		c, server := newTestConn(t)
+		go func() {
+			readMessage(server)
+			writeMessage(server, tableResponse(1, 3, 0, 1)+"!42000!division by zero\n")
+		}()
+
+		q := NewQuery(c, "SELECT 1/0")
+		if err := q.OpenQuery(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := q.NextRow(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := q.NextRow(); err == nil || !strings.Contains(err.Error(), "division by zero") {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Verify close discards the rows", func(t *testing.T) {
+		c, server := newTestConn(t)
+		go func() {
+			readMessage(server)
+			writeMessage(server, tableResponse(1, 100, 0, 100))
+			readMessage(server)
+			writeMessage(server, "&3 1 1\n")
+		}()
+
+		q := NewQuery(c, "SELECT * FROM t")
+		if err := q.OpenQuery(); err != nil {
+			t.Fatal(err)
+		}
+		if err := q.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if c.pending != nil {
+			t.Error("The connection is not released")
+		}
+		resp, err := c.Execute("CREATE TABLE t2 (i int)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp != "&3 1 1\n" {
+			t.Errorf("Unexpected response: %q", resp)
+		}
+	})
+}
+
+func TestHousekeeping(t *testing.T) {
+	srv := mapitest.NewServer(mapitest.HandlerFunc(func(r *mapitest.Request) mapitest.Reply {
+		switch {
+		case strings.HasPrefix(r.Statement, "PREPARE"):
+			return mapitest.Prepared{ID: 7, Params: []string{"int"}}
+		case strings.HasPrefix(r.Statement, "SELECT"):
+			rows := [][]string{{"1"}, {"2"}, {"3"}}
+			if strings.HasSuffix(r.Statement, "LIMIT 1") {
+				rows = rows[:1]
+			}
+			return mapitest.Table{Columns: []string{"i"}, Types: []string{"int"}, Rows: rows}
+		default:
+			return mapitest.Update{Count: 1, LastID: -1}
+		}
+	}))
+	defer srv.Close()
+
+	connect := func(t *testing.T) *mapiConn {
+		cfg, err := ParseDSN(srv.DSN())
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.ReplySize = 2
+		c := NewMapiWithConfig(cfg)
+		if err := c.Connect(); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(c.Disconnect)
+		return c
+	}
+	// sent returns the commands that were sent since before
+	sent := func(before int) []string {
+		return srv.Commands()[before:]
+	}
+
+	t.Run("Verify unfinished result set is closed with the next command", func(t *testing.T) {
+		c := connect(t)
+		q := NewQuery(c, "SELECT i FROM t")
+		if err := q.OpenQuery(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := q.NextRow(); err != nil {
+			t.Fatal(err)
+		}
+		before := len(srv.Commands())
+		if err := q.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if commands := sent(before); len(commands) != 0 {
+			t.Errorf("Commands sent before the next command: %q", commands)
+		}
+		resp, err := c.Execute("INSERT INTO t VALUES (1)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp != "&2 1 -1\n" {
+			t.Errorf("Unexpected response: %q", resp)
+		}
+		if commands := sent(before); len(commands) != 1 || !strings.HasPrefix(commands[0], "close ") {
+			t.Errorf("Unexpected commands: %q", commands)
+		}
+	})
+
+	t.Run("Verify complete result set is not closed", func(t *testing.T) {
+		c := connect(t)
+		q := NewQuery(c, "SELECT i FROM t LIMIT 1")
+		if err := q.OpenQuery(); err != nil {
+			t.Fatal(err)
+		}
+		before := len(srv.Commands())
+		q.Close()
+		if _, err := c.Execute("INSERT INTO t VALUES (1)"); err != nil {
+			t.Fatal(err)
+		}
+		if commands := sent(before); len(commands) != 0 {
+			t.Errorf("Unexpected commands: %q", commands)
+		}
+	})
+
+	t.Run("Verify prepared statement is released once", func(t *testing.T) {
+		c := connect(t)
+		q := NewQuery(c, "INSERT INTO t VALUES (?)")
+		if err := q.PrepareQuery(); err != nil {
+			t.Fatal(err)
+		}
+		before := len(srv.Commands())
+		q.Release()
+		q.Release()
+		if _, err := c.Execute("INSERT INTO t VALUES (1)"); err != nil {
+			t.Fatal(err)
+		}
+		if commands := sent(before); len(commands) != 1 || commands[0] != "release 7" {
+			t.Errorf("Unexpected commands: %q", commands)
+		}
+	})
+}
+
+// The benchmarks read a result of many rows from a connection, to compare
+// the allocations per row of the streaming and the string based parser
+const benchmarkRowCount = 10000
+
+func benchmarkServer(b *testing.B) (*mapiConn, []byte) {
+	c, server := newTestConn(b)
+	data := encodeMessage(tableResponse(1, benchmarkRowCount, 0, benchmarkRowCount))
+	go func() {
+		for {
+			if _, err := readMessage(server); err != nil {
+				return
+			}
+			if _, err := server.Write(data); err != nil {
+				return
+			}
+		}
+	}()
+	return c, data
+}
+
+// benchmarkRows runs the benchmark and reports the allocations per row
+func benchmarkRows(b *testing.B, data []byte, run func()) {
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		run()
+	}
+	b.StopTimer()
+	runtime.ReadMemStats(&after)
+	b.ReportMetric(float64(after.Mallocs-before.Mallocs)/float64(b.N*benchmarkRowCount), "allocs/row")
+}
+
+func BenchmarkNextRow(b *testing.B) {
+	c, data := benchmarkServer(b)
+	benchmarkRows(b, data, func() {
+		q := NewQuery(c, "SELECT * FROM t")
+		if err := q.OpenQuery(); err != nil {
+			b.Fatal(err)
+		}
+		for {
+			if _, err := q.NextRow(); err == io.EOF {
+				break
+			} else if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkStoreResult(b *testing.B) {
+	c, data := benchmarkServer(b)
+	benchmarkRows(b, data, func() {
+		q := NewQuery(c, "SELECT * FROM t")
+		r, err := q.ExecuteQuery()
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := q.StoreResult(r); err != nil {
+			b.Fatal(err)
+		}
+	})
+}
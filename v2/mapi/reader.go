@@ -0,0 +1,343 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+)
+
+// The buffer of the network connection holds a complete block, including
+// the header
+const mapi_READ_BUFFER_SIZE = mapi_MAX_PACKAGE_LENGTH + 2
+
+// Response is the answer of the server to a command. It is read line by
+// line, while the blocks arrive from the server, so a large result does not
+// have to fit in memory.
+type Response interface {
+	// ReadLine returns the next line of the response, without the newline.
+	// The end of the response is marked by an empty line, after which
+	// io.EOF is returned.
+	ReadLine() (string, error)
+	// UnreadLine makes the next call of ReadLine return the last line again
+	UnreadLine()
+	// Close discards the rest of the response
+	Close() error
+}
+
+// input returns the buffered reader of the network connection
+func (c *mapiConn) input() *bufio.Reader {
+	if c.reader == nil || c.readerConn != c.conn {
+		c.reader = bufio.NewReaderSize(c.conn, mapi_READ_BUFFER_SIZE)
+		c.readerConn = c.conn
+	}
+	return c.reader
+}
+
+// readHeader reads the header of the next block, which holds the length of
+// the block and the flag that marks the last block of a message
+func (c *mapiConn) readHeader() (int, bool, error) {
+	c.conn.SetReadDeadline(c.ioDeadline(c.ReadTimeout))
+	var header [2]byte
+	if _, err := io.ReadFull(c.input(), header[:]); err != nil {
+		// The server closed the connection while a message was expected,
+		// which must not look like the end of the message
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, false, c.ioError("read", err)
+	}
+	flag := binary.LittleEndian.Uint16(header[:])
+	return int(flag >> 1), flag&1 == 1, nil
+}
+
+// messageReader returns the content of a single message of the server. The
+// blocks are read from the connection when they are needed, the end of the
+// message is reported as io.EOF.
+type messageReader struct {
+	c         *mapiConn
+	remaining int
+	last      bool
+}
+
+func (m *messageReader) Read(p []byte) (int, error) {
+	for m.remaining == 0 {
+		if m.last {
+			return 0, io.EOF
+		}
+		length, last, err := m.c.readHeader()
+		if err != nil {
+			return 0, err
+		}
+		m.remaining, m.last = length, last
+		if length == 0 && last {
+			m.c.traceReceived(nil, true)
+		}
+	}
+
+	if len(p) > m.remaining {
+		p = p[:m.remaining]
+	}
+	n, err := m.c.input().Read(p)
+	m.remaining -= n
+	m.c.traceReceived(p[:n], m.remaining == 0 && m.last)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return n, m.c.ioError("read", err)
+	}
+	return n, nil
+}
+
+// responseReader reads the response of the server to a command line by line.
+// A response can span several messages, when the server asks for a file
+// transfer in the middle of it. These requests are handled on the way.
+type responseReader struct {
+	c     *mapiConn
+	msg   messageReader
+	lines *bufio.Reader
+
+	// long holds a line that does not fit in the buffer
+	long []byte
+
+	// line is the last line that was read, it is returned again after
+	// UnreadLine
+	line   string
+	unread bool
+
+	// When another command is sent before the response is read, the rest of
+	// the response is moved to memory
+	buffered bool
+	memory   string
+
+	done       bool
+	err        error
+	handlerErr error
+}
+
+// newResponse starts reading the response of the command that was just sent
+func (c *mapiConn) newResponse() *responseReader {
+	r := &responseReader{c: c}
+	r.msg.c = c
+	if c.lines == nil {
+		c.lines = bufio.NewReaderSize(&r.msg, mapi_READ_BUFFER_SIZE)
+	} else {
+		c.lines.Reset(&r.msg)
+	}
+	r.lines = c.lines
+	return r
+}
+
+func (r *responseReader) ReadLine() (string, error) {
+	if r.unread {
+		r.unread = false
+		return r.line, nil
+	}
+	line, err := r.next()
+	if err != nil {
+		return "", err
+	}
+	r.line = line
+	return line, nil
+}
+
+func (r *responseReader) UnreadLine() {
+	r.unread = true
+}
+
+func (r *responseReader) Close() error {
+	r.unread = false
+	for !r.done {
+		if _, err := r.next(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// next returns the next line of the response. At the end of the response an
+// empty line is returned, or the error of a file transfer handler.
+func (r *responseReader) next() (string, error) {
+	if r.err != nil {
+		return "", r.err
+	}
+	if r.done {
+		return "", io.EOF
+	}
+
+	if r.buffered {
+		if r.memory == "" {
+			return r.end()
+		}
+		line, rest, _ := Cut(r.memory, "\n")
+		r.memory = rest
+		return line, nil
+	}
+
+	for {
+		line, err := r.readRaw()
+		if err == io.EOF {
+			if line == "" {
+				return r.end()
+			}
+			// The last line of the message was not terminated
+			return line, nil
+		}
+		if err != nil {
+			r.fail(err)
+			return "", err
+		}
+		if strings.HasPrefix(line, mapi_MSG_INFO) {
+			// The messages are collected by the connection, so they do not
+			// get in the way of the parser
+			r.c.message(line)
+			continue
+		}
+		if line != mapi_MSG_MORE[:2] {
+			r.c.trackResponse(line)
+			return line, nil
+		}
+		if err := r.prompt(); err != nil {
+			r.fail(err)
+			return "", err
+		}
+	}
+}
+
+// readRaw reads a line from the current message. The end of the message is
+// reported as io.EOF.
+func (r *responseReader) readRaw() (string, error) {
+	b, err := r.lines.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		r.long = append(r.long[:0], b...)
+		for err == bufio.ErrBufferFull {
+			b, err = r.lines.ReadSlice('\n')
+			r.long = append(r.long, b...)
+		}
+		b = r.long
+	}
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return string(bytes.TrimSuffix(b, []byte("\n"))), err
+}
+
+// prompt handles a prompt of the server in the middle of the response. It is
+// followed by a file transfer request, or the server waits for the rest of
+// the command, which is empty.
+func (r *responseReader) prompt() error {
+	request, err := r.readRaw()
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	if request == "" {
+		// tell server it isn't going to get more
+		if err := r.c.putBlock(nil); err != nil {
+			return err
+		}
+	} else if err := r.c.handleFileTransfer(request); err != nil {
+		// When the handler failed after the transfer was started, the
+		// server still sends the rest of the response. It must be read to
+		// keep the connection usable.
+		var terr *transferError
+		if !errors.As(err, &terr) {
+			return err
+		}
+		r.handlerErr = err
+	}
+
+	// The response continues in the next message
+	r.msg = messageReader{c: r.c}
+	r.lines.Reset(&r.msg)
+	return nil
+}
+
+// end marks the end of the response, after which the connection is free for
+// the next command
+func (r *responseReader) end() (string, error) {
+	r.done = true
+	r.c.release(r)
+	if r.handlerErr != nil {
+		return "", r.handlerErr
+	}
+	return mapi_MSG_PROMPT, nil
+}
+
+func (r *responseReader) fail(err error) {
+	r.err = err
+	r.c.release(r)
+}
+
+// buffer reads the rest of the response into memory, so the connection can
+// be used for the next command
+func (r *responseReader) buffer() error {
+	if r.buffered {
+		return nil
+	}
+
+	var b strings.Builder
+	for {
+		line, err := r.next()
+		if r.done || err != nil {
+			r.done = false
+			r.buffered = true
+			r.memory = b.String()
+			if r.err != nil {
+				return r.err
+			}
+			return nil
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+}
+
+// release frees the connection after the response of a command has been
+// read
+func (c *mapiConn) release(r *responseReader) {
+	if c.pending == r {
+		c.pending = nil
+		c.setRunning(false)
+	}
+}
+
+// stringResponse returns the lines of a response that is already in memory
+type stringResponse struct {
+	s      string
+	line   string
+	unread bool
+	done   bool
+}
+
+func (r *stringResponse) ReadLine() (string, error) {
+	if r.unread {
+		r.unread = false
+		return r.line, nil
+	}
+	if r.done {
+		return "", io.EOF
+	}
+	line, rest, found := Cut(r.s, "\n")
+	r.s = rest
+	r.done = !found
+	r.line = line
+	return line, nil
+}
+
+func (r *stringResponse) UnreadLine() {
+	r.unread = true
+}
+
+func (r *stringResponse) Close() error {
+	r.done = true
+	r.unread = false
+	return nil
+}
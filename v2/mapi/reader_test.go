@@ -0,0 +1,124 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapi
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+// readLines reads the lines of a response up to the prompt
+func readLines(r Response) ([]string, error) {
+	lines := make([]string, 0)
+	for {
+		line, err := r.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+		if line == mapi_MSG_PROMPT {
+			return lines, nil
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestResponseReader(t *testing.T) {
+	t.Run("Verify lines that span blocks", func(t *testing.T) {
+		c, server := newTestConn(t)
+		long := strings.Repeat("x", 3*mapi_MAX_PACKAGE_LENGTH)
+		expected := []string{"&1 0 3 1 3", long, strings.Repeat("y", 5000), "[ 1\t]"}
+
+		go func() {
+			readMessage(server)
+			writeMessage(server, strings.Join(expected, "\n")+"\n")
+		}()
+
+		r, err := c.ExecuteStream("SELECT 1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines, err := readLines(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(lines) != len(expected) {
+			t.Fatalf("Unexpected number of lines: %d", len(lines))
+		}
+		for i, line := range lines {
+			if line != expected[i] {
+				t.Errorf("Unexpected line %d of %d bytes", i, len(line))
+			}
+		}
+		if _, err := r.ReadLine(); err != io.EOF {
+			t.Errorf("Expected the end of the response, got %v", err)
+		}
+	})
+
+	t.Run("Verify unread line", func(t *testing.T) {
+		r := &stringResponse{s: "a\nb\n"}
+		r.ReadLine()
+		r.UnreadLine()
+		for _, expected := range []string{"a", "b", ""} {
+			if line, _ := r.ReadLine(); line != expected {
+				t.Errorf("Unexpected line %q, expected %q", line, expected)
+			}
+		}
+		if _, err := r.ReadLine(); err != io.EOF {
+			t.Errorf("Expected the end of the response, got %v", err)
+		}
+	})
+
+	t.Run("Verify prompt for more input", func(t *testing.T) {
+		c, server := newTestConn(t)
+		answer := make(chan []byte, 1)
+		go func() {
+			readMessage(server)
+			writeMessage(server, mapi_MSG_MORE)
+			msg, _ := readMessage(server)
+			answer <- msg
+			writeMessage(server, "&2 1 -1\n")
+		}()
+
+		resp, err := c.Execute("INSERT INTO t VALUES (1)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg := <-answer; len(msg) != 0 {
+			t.Errorf("Unexpected answer: %q", msg)
+		}
+		if resp != "&2 1 -1\n" {
+			t.Errorf("Unexpected response: %q", resp)
+		}
+	})
+
+	t.Run("Verify pending response is buffered", func(t *testing.T) {
+		c, server := newTestConn(t)
+		go func() {
+			readMessage(server)
+			writeMessage(server, "[ 1\t]\n[ 2\t]\n")
+			readMessage(server)
+			writeMessage(server, "=OK\n")
+		}()
+
+		r, err := c.ExecuteStream("SELECT 1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line, _ := r.ReadLine(); line != "[ 1\t]" {
+			t.Errorf("Unexpected line: %q", line)
+		}
+		if _, err := c.SetReplySize(10); err != nil {
+			t.Fatal(err)
+		}
+		lines, err := readLines(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(lines) != 1 || lines[0] != "[ 2\t]" {
+			t.Errorf("Unexpected lines: %q", lines)
+		}
+	})
+}
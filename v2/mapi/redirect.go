@@ -0,0 +1,137 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapi
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// The default number of redirects that is followed during the login
+const mapi_MAX_REDIRECTS = 10
+
+// Endpoint is the server that a connection is logged in to. When the
+// connection is redirected, for example by monetdbd, it differs from the
+// endpoint in the Config.
+type Endpoint struct {
+	Hostname string
+	Port     int
+	Socket   string
+	Database string
+	TLS      bool
+}
+
+// String returns the endpoint as a MonetDB URL
+func (e Endpoint) String() string {
+	scheme := "monetdb"
+	if e.TLS {
+		scheme = "monetdbs"
+	}
+	if e.Hostname == "" {
+		return fmt.Sprintf("%s:///%s?sock=%s", scheme, url.PathEscape(e.Database), escapeParam(e.Socket))
+	}
+	return fmt.Sprintf("%s://%s:%d/%s", scheme, e.Hostname, e.Port, url.PathEscape(e.Database))
+}
+
+// Endpoint returns the server the connection is logged in to, after following
+// all redirects
+func (c *mapiConn) Endpoint() Endpoint {
+	return c.endpoint
+}
+
+func (c Config) endpoint() Endpoint {
+	return Endpoint{
+		Hostname: c.Hostname,
+		Port:     c.Port,
+		Socket:   c.Socket,
+		Database: c.Database,
+		TLS:      c.TLS,
+	}
+}
+
+// withEndpoint returns the configuration for a new connection to the given
+// endpoint
+func (c Config) withEndpoint(e Endpoint) Config {
+	c.Hostname = e.Hostname
+	c.Port = e.Port
+	c.Socket = e.Socket
+	c.Database = e.Database
+	c.TLS = e.TLS
+	return c
+}
+
+// redirect is a redirect line that is sent by the server instead of
+// accepting the login, for example
+//
+//	^mapi:merovingian://proxy?database=demo
+//	^mapi:monetdb://localhost:50001/demo?lang=sql&user=monetdb
+//	^mapi:monetdb:///tmp/.s.monetdb.50001?database=demo
+type redirect struct {
+	merovingian bool
+	target      *url.URL
+}
+
+func parseRedirect(line string) (*redirect, error) {
+	target := strings.TrimPrefix(strings.TrimSpace(line), "mapi:")
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("mapi: invalid redirect: %s", line)
+	}
+
+	switch u.Scheme {
+	case "merovingian":
+		// The server that sent the redirect takes care of it, the login is
+		// restarted on the same connection
+		return &redirect{merovingian: true}, nil
+	case "monetdb", "monetdbs":
+		return &redirect{target: u}, nil
+	default:
+		return nil, fmt.Errorf("mapi: unknown redirect: %s", line)
+	}
+}
+
+// parseEndpoint returns the endpoint that the redirect points to. Settings
+// that are not part of the redirect are taken from the current endpoint.
+func (r *redirect) parseEndpoint(current Endpoint) (*Endpoint, error) {
+	u := r.target
+	e := Endpoint{
+		Database: current.Database,
+		TLS:      u.Scheme == "monetdbs",
+	}
+	if current.TLS && !e.TLS {
+		return nil, fmt.Errorf("mapi: refusing redirect to an unencrypted connection: %s", u)
+	}
+
+	query := u.Query()
+	if u.Host == "" {
+		// The path is the Unix domain socket of the server
+		if u.Path == "" {
+			return nil, fmt.Errorf("mapi: redirect without host or socket: %s", u)
+		}
+		e.Socket = u.Path
+	} else {
+		e.Hostname = u.Hostname()
+		if strings.Contains(e.Hostname, ":") {
+			e.Hostname = fmt.Sprintf("[%s]", e.Hostname)
+		}
+		e.Port = 50000
+		if port := u.Port(); port != "" {
+			p, err := strconv.Atoi(port)
+			if err != nil || p < 1 || p > 65535 {
+				return nil, fmt.Errorf("mapi: invalid port in redirect: %s", u)
+			}
+			e.Port = p
+		}
+		if db, _, _ := Cut(strings.TrimPrefix(u.Path, "/"), "/"); db != "" {
+			e.Database = db
+		}
+	}
+	if db := query.Get("database"); db != "" {
+		e.Database = db
+	}
+	return &e, nil
+}
@@ -0,0 +1,174 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapi
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+const testChallenge = "salt:mserver:9:SHA1:LIT:SHA512:"
+
+// newLoginServer starts a server that sends a challenge for every prompt and
+// answers the login response with it. The login responses are returned on
+// the channel.
+func newLoginServer(t *testing.T, prompts ...string) (int, chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	responses := make(chan string, len(prompts))
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for _, prompt := range prompts {
+			writeMessage(conn, testChallenge)
+			msg, err := readMessage(conn)
+			if err != nil {
+				return
+			}
+			responses <- string(msg)
+			writeMessage(conn, prompt)
+		}
+		// Wait until the client closes the connection
+		readMessage(conn)
+	}()
+	return l.Addr().(*net.TCPAddr).Port, responses
+}
+
+func newRedirectTestConn(port int) *mapiConn {
+	c := NewMapiWithConfig(DefaultConfig())
+	c.Hostname = "127.0.0.1"
+	c.Port = port
+	c.Database = "demo"
+	return c
+}
+
+func TestParseRedirect(t *testing.T) {
+	current := Endpoint{Hostname: "db.example.com", Port: 50000, Database: "demo"}
+	tcs := []struct {
+		line     string
+		expected Endpoint
+	}{
+		{"mapi:monetdb://localhost:50001/other?lang=sql&user=monetdb", Endpoint{Hostname: "localhost", Port: 50001, Database: "other"}},
+		{"mapi:monetdb://localhost/", Endpoint{Hostname: "localhost", Port: 50000, Database: "demo"}},
+		{"mapi:monetdb://[::1]:50001/other", Endpoint{Hostname: "[::1]", Port: 50001, Database: "other"}},
+		{"mapi:monetdb:///tmp/.s.monetdb.50001?database=other", Endpoint{Socket: "/tmp/.s.monetdb.50001", Database: "other"}},
+		{"mapi:monetdbs://db2.example.com:50001/other", Endpoint{Hostname: "db2.example.com", Port: 50001, Database: "other", TLS: true}},
+	}
+	for _, tc := range tcs {
+		r, err := parseRedirect(tc.line)
+		if err != nil {
+			t.Errorf("Error parsing redirect %s: %v", tc.line, err)
+			continue
+		}
+		e, err := r.parseEndpoint(current)
+		if err != nil {
+			t.Errorf("Error parsing redirect %s: %v", tc.line, err)
+			continue
+		}
+		if *e != tc.expected {
+			t.Errorf("Unexpected endpoint for %s: %+v", tc.line, *e)
+		}
+	}
+
+	r, err := parseRedirect("mapi:merovingian://proxy?arg=value")
+	if err != nil || !r.merovingian {
+		t.Errorf("Unexpected merovingian redirect: %v %v", r, err)
+	}
+
+	for _, line := range []string{"mapi:other://localhost", "mapi:monetdb://localhost:port/db", "mapi:monetdb://"} {
+		r, err := parseRedirect(line)
+		if err == nil {
+			_, err = r.parseEndpoint(current)
+		}
+		if err == nil {
+			t.Errorf("Expected an error for redirect %s", line)
+		}
+	}
+
+	r, _ = parseRedirect("mapi:monetdb://localhost:50001/demo")
+	if _, err := r.parseEndpoint(Endpoint{TLS: true}); err == nil {
+		t.Error("Expected an error for a redirect to an unencrypted connection")
+	}
+}
+
+func TestRedirect(t *testing.T) {
+	t.Run("Verify redirect to another server", func(t *testing.T) {
+		port, responses := newLoginServer(t, "")
+		proxy, _ := newLoginServer(t, fmt.Sprintf("^mapi:monetdb://127.0.0.1:%d/other?lang=sql\n^mapi:monetdb://localhost:1/ignored\n", port))
+
+		c := newRedirectTestConn(proxy)
+		if err := c.connect(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		defer c.Disconnect()
+
+		expected := Endpoint{Hostname: "127.0.0.1", Port: port, Database: "other"}
+		if c.Endpoint() != expected {
+			t.Errorf("Unexpected endpoint: %s", c.Endpoint())
+		}
+		if c.Hostname != "127.0.0.1" || c.Port != proxy || c.Database != "demo" {
+			t.Errorf("Configuration was changed: %s:%d/%s", c.Hostname, c.Port, c.Database)
+		}
+		if r := <-responses; !strings.Contains(r, ":sql:other:") {
+			t.Errorf("Login to wrong database: %s", r)
+		}
+	})
+
+	t.Run("Verify merovingian redirect", func(t *testing.T) {
+		port, responses := newLoginServer(t, "^mapi:merovingian://proxy\n", "=OK\n")
+
+		c := newRedirectTestConn(port)
+		if err := c.connect(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		defer c.Disconnect()
+		if c.State != mapi_STATE_READY {
+			t.Error("Connection is not ready")
+		}
+		if len(responses) != 2 {
+			t.Errorf("Unexpected number of logins: %d", len(responses))
+		}
+	})
+
+	t.Run("Verify redirect limit", func(t *testing.T) {
+		port, _ := newLoginServer(t, "^mapi:merovingian://proxy\n", "^mapi:merovingian://proxy\n", "^mapi:merovingian://proxy\n")
+
+		c := newRedirectTestConn(port)
+		c.MaxRedirects = 2
+		err := c.connect(context.Background())
+		if err == nil || !strings.Contains(err.Error(), "redirects") {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Verify failing redirect", func(t *testing.T) {
+		proxy, _ := newLoginServer(t, "^mapi:monetdb://127.0.0.1:1/other\n")
+
+		c := newRedirectTestConn(proxy)
+		if err := c.connect(context.Background()); err == nil {
+			t.Error("Expected an error")
+		}
+	})
+
+	t.Run("Verify login error", func(t *testing.T) {
+		port, _ := newLoginServer(t, "!InvalidCredentialsException:checkCredentials:invalid credentials for user 'monetdb'\n")
+
+		c := newRedirectTestConn(port)
+		err := c.connect(context.Background())
+		if err == nil || !strings.Contains(err.Error(), "invalid credentials") {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+}
@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"fmt"
 	"strings"
+	"time"
 )
 
 type TableElement struct {
@@ -35,23 +36,59 @@ type ResultSet struct {
 	Metadata Metadata
 	Schema []TableElement
 	Rows [][]Value
+
+	// location is the time zone of the timestamps with time zone
+	location *time.Location
 }
 
 func (s *ResultSet) parseTuple(d string) ([]Value, error) {
-	items := strings.Split(d[1:len(d)-1], ",\t")
-	if len(items) != len(s.Schema) {
-		return nil, fmt.Errorf("mapi: length of row doesn't match header")
+	return s.parseRow(d, nil)
+}
+
+// parseRow converts the values of a tuple. They are stored in row when it is
+// large enough, so it can be reused for all the rows of the result set.
+func (s *ResultSet) parseRow(d string, row []Value) ([]Value, error) {
+	if cap(row) < len(s.Schema) {
+		row = make([]Value, len(s.Schema))
 	}
+	row = row[:len(s.Schema)]
 
-	v := make([]Value, len(items))
-	for i, value := range items {
-		vv, err := s.convert(value, s.Schema[i].ColumnType)
+	items := d[1 : len(d)-1]
+	for i := range row {
+		value, rest, found := cutField(items)
+		if found != (i < len(row)-1) {
+			return nil, fmt.Errorf("mapi: length of row doesn't match header")
+		}
+		vv, err := s.convert(value, s.Schema[i])
 		if err != nil {
 			return nil, err
 		}
-		v[i] = vv
+		row[i] = vv
+		items = rest
 	}
-	return v, nil
+	return row, nil
+}
+
+// cutField cuts the first value from the fields of a tuple, which are
+// separated by a comma and a tab. A quoted string can contain the separator.
+func cutField(d string) (string, string, bool) {
+	start := len(d) - len(strings.TrimLeft(d, " "))
+	if start < len(d) && d[start] == '"' {
+		for start++; start < len(d) && d[start] != '"'; start++ {
+			if d[start] == '\\' {
+				start++
+			}
+		}
+		if start > len(d) {
+			start = len(d)
+		}
+	}
+
+	i := strings.Index(d[start:], ",\t")
+	if i < 0 {
+		return d, "", false
+	}
+	return d[:start+i], d[start+i+2:], true
 }
 
 func (s *ResultSet) updateSchema(
@@ -75,8 +112,15 @@ func (s *ResultSet) updateSchema(
 	s.Schema = d
 }
 
-func (s *ResultSet) convert(value, dataType string) (Value, error) {
-	val, err := convertToGo(value, dataType)
+func (s *ResultSet) convert(value string, column TableElement) (Value, error) {
+	// A decimal gets the scale of the column from the typesizes header
+	if column.ColumnType == MDB_DECIMAL && strings.TrimSpace(value) != "NULL" {
+		return toDecimal(strings.TrimSpace(value), column.Scale)
+	}
+	val, err := convertToGo(value, column.ColumnType)
+	if t, ok := val.(time.Time); ok && column.ColumnType == MDB_TIMESTAMPTZ && s.location != nil {
+		val = t.In(s.location)
+	}
 	return val, err
 }
 
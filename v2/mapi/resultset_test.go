@@ -75,6 +75,39 @@ func TestResultSetStoreResult(t *testing.T) {
 		//}
 	})
 
+	t.Run("Verify StoreResult from tab separated create table", func(t *testing.T) {
+		var r = NewQuery(nil, "")
+		var response = "&5 0 0 6 0 0 0 0 20\n" +
+			"% .prepare,\t.prepare,\t.prepare,\t.prepare,\t.prepare,\t.prepare # table_name\n" +
+			"% type,\tdigits,\tscale,\tschema,\ttable,\tcolumn # name\n" +
+			"% varchar,\tint,\tint,\tvarchar,\tvarchar,\tvarchar # type\n" +
+			"% 0,\t1,\t1,\t0,\t0,\t0 # length\n" +
+			"% 0 0,\t1 0,\t1 0,\t0 0,\t0 0,\t0 0 # typesizes\n" +
+			"&3 128 127\n"
+		err := r.StoreResult(response)
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Verify StoreResult from tab separated prepare select star", func(t *testing.T) {
+		var r = NewQuery(nil, "")
+		var response = "&5 2 1 6 1 0 0 0 36\n" +
+			"% .prepare,\t.prepare,\t.prepare,\t.prepare,\t.prepare,\t.prepare # table_name\n" +
+			"% type,\tdigits,\tscale,\tschema,\ttable,\tcolumn # name\n" +
+			"% varchar,\tint,\tint,\tvarchar,\tvarchar,\tvarchar # type\n" +
+			"% 7,\t2,\t1,\t0,\t5,\t4 # length\n" +
+			"% 7 0,\t2 0,\t1 0,\t0 0,\t0 0,\t4 0 # typesizes\n" +
+			"[ \"varchar\",\t16,\t0,\t\"\",\t\"test1\",\t\"name\"\t]\n"
+		err := r.StoreResult(response)
+		if err != nil {
+			t.Error(err)
+		}
+		if params := r.Params(); params == nil || len(params) != 0 {
+			t.Errorf("Unexpected parameters: %v", params)
+		}
+	})
+
 	t.Run("Verify StoreResult from prepare select star", func(t *testing.T) {
 		var r = NewQuery(nil, "")
 		var response = `&1 2 1 1 1 0 201 169 7
@@ -99,3 +132,37 @@ func TestResultSetStoreResult(t *testing.T) {
 	})
 
 }
+
+func TestResultSetParseTuple(t *testing.T) {
+	t.Run("Verify string that contains the separator", func(t *testing.T) {
+		r := ResultSet{Schema: []TableElement{{ColumnType: MDB_VARCHAR}, {ColumnType: MDB_INT}}}
+		row, err := r.parseTuple("[ \"a,\tb\",\t42\t]")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if row[0] != "a,\tb" || row[1] != int32(42) {
+			t.Errorf("Unexpected row: %q", row)
+		}
+
+		field, rest, found := cutField(" \"a\\\",\tb\",\t42")
+		if field != " \"a\\\",\tb\"" || rest != "42" || !found {
+			t.Errorf("Unexpected field %q and rest %q", field, rest)
+		}
+	})
+
+	t.Run("Verify length of row", func(t *testing.T) {
+		r := ResultSet{Schema: []TableElement{{ColumnType: MDB_INT}}}
+		if _, err := r.parseTuple("[ 1,\t2\t]"); err == nil {
+			t.Error("Expected an error")
+		}
+	})
+
+	t.Run("Verify row is reused", func(t *testing.T) {
+		r := ResultSet{Schema: []TableElement{{ColumnType: MDB_INT}}}
+		first, _ := r.parseRow("[ 1\t]", nil)
+		second, _ := r.parseRow("[ 2\t]", first)
+		if &first[0] != &second[0] || second[0] != int32(2) {
+			t.Errorf("Unexpected row: %v", second)
+		}
+	})
+}
@@ -0,0 +1,209 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapi
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Session settings that the server accepts in the login response. The
+// server advertises a level in the challenge, for example sql=6, and accepts
+// every option with a lower level.
+const (
+	mapi_HANDSHAKE_AUTO_COMMIT = 1
+	mapi_HANDSHAKE_REPLY_SIZE  = 2
+	mapi_HANDSHAKE_SIZE_HEADER = 3
+	mapi_HANDSHAKE_TIME_ZONE   = 5
+)
+
+// parseHandshakeLevel returns the level of the handshake options that the
+// server supports for our language, or zero when it does not support them
+func (c *mapiConn) parseHandshakeLevel(options []string) int {
+	for _, option := range options {
+		name, value, found := Cut(option, "=")
+		if found && name == c.Language {
+			if level, err := strconv.Atoi(value); err == nil {
+				return level
+			}
+		}
+	}
+	return 0
+}
+
+// handshakeOptions returns the session settings that are sent in the login
+// response. The settings that are sent are recorded, so they are not sent
+// again with a separate command after the login.
+func (c *mapiConn) handshakeOptions(level int) string {
+	c.handshake = make(map[string]bool)
+	options := make([]string, 0)
+	add := func(optionLevel int, name string, value int) {
+		if optionLevel < level {
+			options = append(options, fmt.Sprintf("%s=%d", name, value))
+			c.handshake[name] = true
+		}
+	}
+
+	add(mapi_HANDSHAKE_AUTO_COMMIT, "auto_commit", boolToInt(c.AutoCommit))
+	add(mapi_HANDSHAKE_REPLY_SIZE, "reply_size", c.ReplySize)
+	add(mapi_HANDSHAKE_SIZE_HEADER, "size_header", boolToInt(c.Sizeheader))
+	if c.Timezone != nil {
+		add(mapi_HANDSHAKE_TIME_ZONE, "time_zone", timezoneOffset(c.Timezone))
+	}
+	return strings.Join(options, ",")
+}
+
+// configureSession applies the session settings of the Config that were not
+// sent in the login response.
+func (c *mapiConn) configureSession() error {
+	if c.handshake["auto_commit"] {
+		c.autoCommit = c.AutoCommit
+	} else if _, err := c.SetAutoCommit(c.AutoCommit); err != nil {
+		return err
+	}
+
+	if c.handshake["reply_size"] {
+		c.replySize = c.ReplySize
+	} else if _, err := c.SetReplySize(c.ReplySize); err != nil {
+		return err
+	}
+
+	if c.handshake["size_header"] {
+		c.sizeHeader = c.Sizeheader
+	} else if _, err := c.SetSizeHeader(c.Sizeheader); err != nil {
+		return err
+	}
+
+	if c.Timezone != nil {
+		if c.handshake["time_zone"] {
+			c.timezone = c.Timezone
+		} else if err := c.SetServerTimezone(c.Timezone); err != nil {
+			return err
+		}
+	}
+
+	if c.Schema != "" {
+		if err := c.SetSchema(c.Schema); err != nil {
+			return err
+		}
+	}
+
+	// Without autocommit every statement is part of a transaction
+	c.transaction = !c.autoCommit
+	c.changed = false
+	return nil
+}
+
+// setPattern matches the SET statements, which may change the time zone or
+// the schema of the session
+var setPattern = regexp.MustCompile(`(?i)(^|;)\s*SET\s`)
+
+// trackCommand records that a command may change the session settings
+func (c *mapiConn) trackCommand(operation string) {
+	if strings.HasPrefix(operation, "s") && setPattern.MatchString(operation[1:]) {
+		c.changed = true
+	}
+}
+
+// trackResponse follows the state of the transaction. The server reports
+// the autocommit state after START TRANSACTION, COMMIT and ROLLBACK.
+func (c *mapiConn) trackResponse(line string) {
+	if strings.HasPrefix(line, mapi_MSG_QTRANS) {
+		c.transaction = strings.TrimSpace(line[len(mapi_MSG_QTRANS):]) == "f"
+	}
+}
+
+// Valid reports whether the connection can be used for the next command. It
+// is false when the connection is closed, or after an error of the network
+// connection.
+func (c *mapiConn) Valid() bool {
+	return c.State == mapi_STATE_READY && c.conn != nil && !c.broken
+}
+
+// Location returns the time zone of the Config, into which the values of
+// TIMESTAMP WITH TIME ZONE columns are converted
+func (c *mapiConn) Location() *time.Location {
+	return c.Timezone
+}
+
+// ResetSession restores the session settings of the Config, after they may
+// have been changed by the statements on the connection. A transaction that
+// is still open is rolled back.
+func (c *mapiConn) ResetSession() error {
+	if c.transaction {
+		if _, err := c.Execute("ROLLBACK"); err != nil {
+			return err
+		}
+	}
+	if c.autoCommit != c.AutoCommit {
+		if _, err := c.SetAutoCommit(c.AutoCommit); err != nil {
+			return err
+		}
+	}
+	if c.replySize != c.ReplySize {
+		if _, err := c.SetReplySize(c.ReplySize); err != nil {
+			return err
+		}
+	}
+	if !c.changed {
+		return nil
+	}
+
+	if c.Timezone != nil {
+		if err := c.setTimezone(c.Timezone); err != nil {
+			return err
+		}
+	} else if _, err := c.Execute("SET TIME ZONE LOCAL"); err != nil {
+		return err
+	}
+	schema := c.Schema
+	if schema == "" {
+		var err error
+		if schema, err = c.defaultSchema(); err != nil {
+			return err
+		}
+	}
+	if err := c.SetSchema(schema); err != nil {
+		return err
+	}
+	c.changed = false
+	return nil
+}
+
+// defaultSchema returns the schema that the user gets at the login
+func (c *mapiConn) defaultSchema() (string, error) {
+	q := NewQuery(c, "SELECT s.name FROM sys.schemas s, sys.users u WHERE s.id = u.default_schema AND u.name = CURRENT_USER")
+	r, err := q.ExecuteQuery()
+	if err != nil {
+		return "", err
+	}
+	if err := q.StoreResult(r); err != nil {
+		return "", err
+	}
+	rs := q.Result()
+	if rs == nil || len(rs.Rows) != 1 || len(rs.Rows[0]) != 1 {
+		return "", fmt.Errorf("mapi: default schema not found")
+	}
+	return fmt.Sprint(rs.Rows[0][0]), nil
+}
+
+// timezoneOffset returns the offset of the timezone in seconds east of UTC.
+// The date we use does not matter, we are only interested in the offset of
+// the timezone.
+func timezoneOffset(timezone *time.Location) int {
+	tm := time.Date(2024, 2, 29, 0, 0, 0, 0, timezone)
+	_, offset := tm.Zone()
+	return offset
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
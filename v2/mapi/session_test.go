@@ -0,0 +1,197 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapi
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MonetDB/MonetDB-Go/v2/mapitest"
+)
+
+func TestHandshakeOptions(t *testing.T) {
+	newConn := func() *mapiConn {
+		c := NewMapiWithConfig(DefaultConfig())
+		c.AutoCommit = false
+		c.ReplySize = 250
+		c.Timezone = time.FixedZone("+02:00", 7200)
+		return c
+	}
+
+	tcs := []struct {
+		challenge string
+		expected  string
+		sent      int
+	}{
+		{"salt:mserver:9:SHA512:LIT:SHA512:sql=6:BINARY=1:", ":FILETRANS:auto_commit=0,reply_size=250,size_header=1,time_zone=7200:", 4},
+		{"salt:mserver:9:SHA512:LIT:SHA512:sql=3:", ":FILETRANS:auto_commit=0,reply_size=250:", 2},
+		{"salt:mserver:9:SHA512:LIT:SHA512:", ":FILETRANS:", 0},
+	}
+	for _, tc := range tcs {
+		c := newConn()
+		r, err := c.challengeResponse([]byte(tc.challenge))
+		if err != nil {
+			t.Errorf("Error responding to %s: %v", tc.challenge, err)
+			continue
+		}
+		if !strings.HasSuffix(r, tc.expected) {
+			t.Errorf("Unexpected response for %s: %s", tc.challenge, r)
+		}
+		if len(c.handshake) != tc.sent {
+			t.Errorf("Unexpected number of handshake options for %s: %d", tc.challenge, len(c.handshake))
+		}
+	}
+}
+
+func TestConfigureSession(t *testing.T) {
+	t.Run("Verify settings not sent in the handshake", func(t *testing.T) {
+		c, server := newTestConn(t)
+		c.handshake = map[string]bool{"auto_commit": true, "reply_size": true}
+		c.AutoCommit = false
+		c.Timezone = time.FixedZone("+01:00", 3600)
+		c.Schema = "sys"
+
+		received := make(chan string, 3)
+		go func() {
+			for i := 0; i < 3; i++ {
+				msg, err := readMessage(server)
+				if err != nil {
+					return
+				}
+				received <- string(msg)
+				writeMessage(server, "")
+			}
+		}()
+
+		if err := c.configureSession(); err != nil {
+			t.Fatal(err)
+		}
+		expected := []string{
+			"Xsizeheader 1",
+			"sSET TIME ZONE INTERVAL '+01:00' HOUR TO MINUTE;;",
+			"sSET SCHEMA \"sys\";",
+		}
+		for _, e := range expected {
+			if msg := <-received; msg != e {
+				t.Errorf("Unexpected command: %s, expected: %s", msg, e)
+			}
+		}
+		if c.autoCommit || c.timezone != c.Timezone {
+			t.Error("Session state was not updated")
+		}
+	})
+
+	t.Run("Verify failing setting", func(t *testing.T) {
+		c, server := newTestConn(t)
+		go func() {
+			readMessage(server)
+			writeMessage(server, "!42000!auto_commit failed\n")
+		}()
+
+		err := c.configureSession()
+		if err == nil || !strings.Contains(err.Error(), "auto_commit failed") {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+}
+
+// sessionServer answers the transaction statements like the server
+func sessionServer(t *testing.T) *mapitest.Server {
+	srv := mapitest.NewServer(mapitest.HandlerFunc(func(r *mapitest.Request) mapitest.Reply {
+		switch {
+		case r.Statement == "START TRANSACTION":
+			return mapitest.Transaction{AutoCommit: false}
+		case r.Statement == "ROLLBACK":
+			return mapitest.Transaction{AutoCommit: true}
+		case strings.HasPrefix(r.Statement, "SELECT s.name FROM sys.schemas"):
+			return mapitest.Table{Columns: []string{"name"}, Types: []string{"varchar"}, Rows: [][]string{{"\"sys\""}}}
+		case r.Statement == "CRASH":
+			return mapitest.Hangup{}
+		default:
+			return mapitest.Schema{}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestResetSession(t *testing.T) {
+	// since returns the statements after the first n
+	since := func(srv *mapitest.Server, n int) string {
+		return strings.Join(srv.Statements()[n:], "; ")
+	}
+
+	t.Run("Verify unchanged session is not reset", func(t *testing.T) {
+		srv := sessionServer(t)
+		c := connectTo(t, srv, nil, nil)
+		c.Execute("SELECT 1")
+		n := len(srv.Statements())
+		if err := c.ResetSession(); err != nil {
+			t.Fatal(err)
+		}
+		if s := since(srv, n); s != "" {
+			t.Errorf("Unexpected statements: %s", s)
+		}
+	})
+
+	t.Run("Verify transaction is rolled back", func(t *testing.T) {
+		srv := sessionServer(t)
+		c := connectTo(t, srv, nil, nil)
+		c.Execute("START TRANSACTION")
+		n := len(srv.Statements())
+		if err := c.ResetSession(); err != nil {
+			t.Fatal(err)
+		}
+		if s := since(srv, n); s != "ROLLBACK" {
+			t.Errorf("Unexpected statements: %s", s)
+		}
+		if c.transaction {
+			t.Error("The transaction is still open")
+		}
+	})
+
+	t.Run("Verify settings are restored", func(t *testing.T) {
+		srv := sessionServer(t)
+		c := connectTo(t, srv, nil, nil)
+		c.Timezone = time.UTC
+		c.SetReplySize(10)
+		c.Execute("SET SCHEMA tmp")
+		n := len(srv.Statements())
+		if err := c.ResetSession(); err != nil {
+			t.Fatal(err)
+		}
+		expected := "SET TIME ZONE INTERVAL '+00:00' HOUR TO MINUTE; SELECT s.name FROM sys.schemas s, sys.users u WHERE s.id = u.default_schema AND u.name = CURRENT_USER; SET SCHEMA \"sys\""
+		if s := since(srv, n); s != expected {
+			t.Errorf("Unexpected statements: %s", s)
+		}
+		if commands := srv.Commands(); commands[len(commands)-1] != "reply_size 100" {
+			t.Errorf("Unexpected commands: %q", commands)
+		}
+		if c.changed {
+			t.Error("The session is still marked as changed")
+		}
+	})
+
+	t.Run("Verify connection is invalid after an error", func(t *testing.T) {
+		srv := sessionServer(t)
+		c := connectTo(t, srv, nil, nil)
+		if !c.Valid() {
+			t.Fatal("New connection is not valid")
+		}
+		if _, err := c.Execute("SELECT 1 FROM nosuchtable"); err != nil {
+			t.Fatal(err)
+		}
+		if !c.Valid() {
+			t.Error("Connection is not valid after a statement")
+		}
+		if _, err := c.Execute("CRASH"); err == nil {
+			t.Fatal("Expected an error")
+		}
+		if c.Valid() {
+			t.Error("Connection is valid after an I/O error")
+		}
+	})
+}
@@ -0,0 +1,95 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// TimeoutError is returned when connecting, reading or writing takes longer
+// than the configured timeout or the deadline of the context. After a read
+// or write timeout the connection is closed, because the rest of the message
+// may still arrive.
+type TimeoutError struct {
+	Op  string
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("mapi: %s timeout: %v", e.Op, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout makes the error usable as a net.Error
+func (e *TimeoutError) Timeout() bool {
+	return true
+}
+
+func (e *TimeoutError) Temporary() bool {
+	return false
+}
+
+// SetDeadline sets the time at which the commands that follow fail with a
+// TimeoutError. The zero value removes the deadline. The read and write
+// timeouts still apply when they expire earlier.
+func (c *mapiConn) SetDeadline(t time.Time) {
+	c.deadline = t
+}
+
+// ioDeadline returns the deadline for the next read or write
+func (c *mapiConn) ioDeadline(timeout time.Duration) time.Time {
+	d := c.deadline
+	if timeout > 0 {
+		t := time.Now().Add(timeout)
+		if d.IsZero() || t.Before(d) {
+			d = t
+		}
+	}
+	return d
+}
+
+// ioError converts a timeout of the network connection into a TimeoutError.
+// After an error the connection is no longer valid.
+func (c *mapiConn) ioError(op string, err error) error {
+	// The state of the protocol is unknown, the connection cannot be used
+	// anymore
+	c.broken = true
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		c.conn.Close()
+		return &TimeoutError{Op: op, Err: err}
+	}
+	return err
+}
+
+// watchContext closes the connection when the context is done before the
+// returned function is called
+func watchContext(ctx context.Context, conn net.Conn) func() {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
@@ -0,0 +1,114 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapi
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// newSilentServer accepts connections but never sends the login challenge
+func newSilentServer(t *testing.T) *mapiConn {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+
+	c := NewMapiWithConfig(DefaultConfig())
+	c.Hostname = "127.0.0.1"
+	c.Port = l.Addr().(*net.TCPAddr).Port
+	return c
+}
+
+func TestConnectTimeout(t *testing.T) {
+	t.Run("Verify connect timeout", func(t *testing.T) {
+		c := newSilentServer(t)
+		c.ConnectTimeout = 50 * time.Millisecond
+
+		err := c.Connect()
+		var terr *TimeoutError
+		if !errors.As(err, &terr) || terr.Op != "connect" {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Error does not wrap the deadline: %v", err)
+		}
+	})
+
+	t.Run("Verify connect is cancelled", func(t *testing.T) {
+		c := newSilentServer(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		if err := c.ConnectContext(ctx); !errors.Is(err, context.Canceled) {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+}
+
+func TestIOTimeout(t *testing.T) {
+	t.Run("Verify read timeout", func(t *testing.T) {
+		c, server := newTestConn(t)
+		c.ReadTimeout = 50 * time.Millisecond
+		go readMessage(server)
+
+		_, err := c.Execute("SELECT 1")
+		var terr *TimeoutError
+		if !errors.As(err, &terr) || terr.Op != "read" {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Verify write timeout", func(t *testing.T) {
+		c, _ := newTestConn(t)
+		c.WriteTimeout = 50 * time.Millisecond
+
+		_, err := c.Execute("SELECT 1")
+		var terr *TimeoutError
+		if !errors.As(err, &terr) || terr.Op != "write" {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Verify deadline", func(t *testing.T) {
+		c, server := newTestConn(t)
+		c.ReadTimeout = time.Hour
+		c.SetDeadline(time.Now().Add(50 * time.Millisecond))
+		go readMessage(server)
+
+		_, err := c.Execute("SELECT 1")
+		var nerr net.Error
+		if !errors.As(err, &nerr) || !nerr.Timeout() {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Verify deadline is removed", func(t *testing.T) {
+		c, server := newTestConn(t)
+		c.SetDeadline(time.Now().Add(-time.Second))
+		c.SetDeadline(time.Time{})
+		go func() {
+			readMessage(server)
+			writeMessage(server, "&2 1 -1\n")
+		}()
+
+		if _, err := c.Execute("SELECT 1"); err != nil {
+			t.Error(err)
+		}
+	})
+}
@@ -0,0 +1,145 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapi
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// The protocol name the server expects in the ALPN extension of the TLS handshake.
+const mapi_ALPN_PROTOCOL = "mapi/9"
+
+// startTLS performs the TLS handshake on a freshly dialed connection. The
+// server, or a TLS terminating proxy in front of it, sees an ordinary TLS
+// client. After the handshake the MAPI blocks are sent over the encrypted
+// connection, just like they would be on a plain connection.
+func (c *mapiConn) startTLS(ctx context.Context, conn net.Conn, hostname string) (net.Conn, error) {
+	cfg, err := c.tlsConfig(hostname)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	tlsConn := tls.Client(conn, cfg)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("mapi: tls handshake failed: %w", err)
+	}
+	return tlsConn, nil
+}
+
+// tlsConfig combines the tls.Config provided by the user with the settings
+// from the connection handle. The user provided value is never modified, a
+// certificate file cannot replace the RootCAs it already has and its
+// VerifyPeerCertificate still runs before the certificate hash is checked.
+func (c *mapiConn) tlsConfig(hostname string) (*tls.Config, error) {
+	var cfg *tls.Config
+	if c.TLSConfig != nil {
+		cfg = c.TLSConfig.Clone()
+	} else {
+		cfg = &tls.Config{}
+	}
+
+	if cfg.ServerName == "" {
+		cfg.ServerName = strings.Trim(hostname, "[]")
+	}
+	if len(cfg.NextProtos) == 0 {
+		cfg.NextProtos = []string{mapi_ALPN_PROTOCOL}
+	}
+	if c.ClientCert != nil {
+		cfg.Certificates = append(cfg.Certificates, *c.ClientCert)
+	}
+
+	if c.CertFile != "" {
+		if cfg.RootCAs != nil {
+			return nil, fmt.Errorf("mapi: parameter cert cannot be used with the RootCAs of the tls.Config")
+		}
+		pem, err := os.ReadFile(c.CertFile)
+		if err != nil {
+			return nil, fmt.Errorf("mapi: cannot read certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("mapi: no certificates found in %s", c.CertFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if c.ClientKeyFile != "" {
+		// The certificate may be stored in the same file as the key
+		certFile := c.ClientCertFile
+		if certFile == "" {
+			certFile = c.ClientKeyFile
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, c.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("mapi: cannot load client certificate: %w", err)
+		}
+		cfg.Certificates = append(cfg.Certificates, cert)
+	}
+
+	if c.CertHash != "" {
+		prefix, err := parseCertHash(c.CertHash)
+		if err != nil {
+			return nil, err
+		}
+		// When the certificate is pinned, the normal verification against the
+		// trusted root certificates is replaced by comparing the hash.
+		cfg.InsecureSkipVerify = true
+		verify := cfg.VerifyPeerCertificate
+		cfg.VerifyPeerCertificate = func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+			if verify != nil {
+				if err := verify(rawCerts, verifiedChains); err != nil {
+					return err
+				}
+			}
+			return verifyCertHash(prefix, rawCerts)
+		}
+	}
+
+	return cfg, nil
+}
+
+// parseCertHash validates a certificate hash in the form sha256:<hexdigits>.
+// The hex digits may be separated by colons, as printed by openssl. Only a
+// prefix of the hash needs to be given. The normalized prefix is returned.
+func parseCertHash(certHash string) (string, error) {
+	algo, digits, found := Cut(certHash, ":")
+	if !found || strings.ToLower(algo) != "sha256" {
+		return "", fmt.Errorf("mapi: unsupported certificate hash: %s", certHash)
+	}
+
+	prefix := strings.ToLower(strings.ReplaceAll(digits, ":", ""))
+	if prefix == "" {
+		return "", fmt.Errorf("mapi: empty certificate hash: %s", certHash)
+	}
+	for _, r := range prefix {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return "", fmt.Errorf("mapi: invalid certificate hash: %s", certHash)
+		}
+	}
+	return prefix, nil
+}
+
+// verifyCertHash checks that the hash of the leaf certificate of the server
+// starts with the given prefix.
+func verifyCertHash(prefix string, rawCerts [][]byte) error {
+	if len(rawCerts) == 0 {
+		return fmt.Errorf("mapi: server did not present a certificate")
+	}
+	sum := sha256.Sum256(rawCerts[0])
+	if !strings.HasPrefix(hex.EncodeToString(sum[:]), prefix) {
+		return fmt.Errorf("mapi: server certificate does not match hash sha256:%s", prefix)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapi
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestCertificate(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestParseCertHash(t *testing.T) {
+	tcs := [][]string{
+		{"sha256:ABCDEF", "abcdef"},
+		{"sha256:ab:cd:ef", "abcdef"},
+		{"sha256:"},
+		{"md5:abcdef"},
+		{"abcdef"},
+		{"sha256:xyz"},
+	}
+
+	for _, tc := range tcs {
+		prefix, err := parseCertHash(tc[0])
+		if len(tc) == 1 {
+			if err == nil {
+				t.Errorf("Error parsing invalid certificate hash: %s", tc[0])
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Error parsing certificate hash: %s -> %v", tc[0], err)
+		} else if prefix != tc[1] {
+			t.Errorf("Invalid prefix: %s, expected: %s", prefix, tc[1])
+		}
+	}
+}
+
+func TestStartTLS(t *testing.T) {
+	cert := newTestCertificate(t)
+	sum := sha256.Sum256(cert.Certificate[0])
+	hash := hex.EncodeToString(sum[:])
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{mapi_ALPN_PROTOCOL},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	port := l.Addr().(*net.TCPAddr).Port
+
+	t.Run("Verify handshake with pinned certificate", func(t *testing.T) {
+		c := mapiConn{Config: Config{Hostname: "127.0.0.1", Port: port, TLS: true, CertHash: "sha256:" + hash[:16]}}
+		conn, err := c.dial(context.Background(), c.Config.endpoint())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		state := conn.(*tls.Conn).ConnectionState()
+		if state.NegotiatedProtocol != mapi_ALPN_PROTOCOL {
+			t.Errorf("Unexpected protocol: %s", state.NegotiatedProtocol)
+		}
+	})
+
+	t.Run("Verify handshake fails with wrong certificate hash", func(t *testing.T) {
+		c := mapiConn{Config: Config{Hostname: "127.0.0.1", Port: port, TLS: true, CertHash: "sha256:0000"}}
+		if hash[:4] == "0000" {
+			c.CertHash = "sha256:ffff"
+		}
+		if _, err := c.dial(context.Background(), c.Config.endpoint()); err == nil {
+			t.Error("Expected handshake to fail")
+		}
+	})
+
+	t.Run("Verify handshake fails with untrusted certificate", func(t *testing.T) {
+		c := mapiConn{Config: Config{Hostname: "127.0.0.1", Port: port, TLS: true}}
+		if _, err := c.dial(context.Background(), c.Config.endpoint()); err == nil {
+			t.Error("Expected handshake to fail")
+		}
+	})
+
+	t.Run("Verify handshake with trusted root certificate", func(t *testing.T) {
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		pool := x509.NewCertPool()
+		pool.AddCert(leaf)
+		c := mapiConn{
+			Config:    Config{Hostname: "127.0.0.1", Port: port, TLS: true},
+			TLSConfig: &tls.Config{RootCAs: pool, ServerName: "localhost"},
+		}
+		conn, err := c.dial(context.Background(), c.Config.endpoint())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	})
+
+	t.Run("Verify pinned certificate keeps the verification of the user", func(t *testing.T) {
+		var called bool
+		c := mapiConn{
+			Config: Config{Hostname: "127.0.0.1", Port: port, TLS: true, CertHash: "sha256:" + hash[:16]},
+			TLSConfig: &tls.Config{VerifyPeerCertificate: func([][]byte, [][]*x509.Certificate) error {
+				called = true
+				return errors.New("rejected")
+			}},
+		}
+		if _, err := c.dial(context.Background(), c.Config.endpoint()); err == nil {
+			t.Error("Expected handshake to fail")
+		}
+		if !called {
+			t.Error("Expected the verification of the user to be called")
+		}
+	})
+
+	t.Run("Verify certificate file conflicts with root certificates", func(t *testing.T) {
+		c := mapiConn{
+			Config:    Config{Hostname: "127.0.0.1", Port: port, TLS: true, CertFile: "server.crt"},
+			TLSConfig: &tls.Config{RootCAs: x509.NewCertPool()},
+		}
+		if _, err := c.tlsConfig("localhost"); err == nil || !strings.Contains(err.Error(), "RootCAs") {
+			t.Errorf("Expected conflict error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,216 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapi
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The directions of the messages in a transcript
+const (
+	trace_SENT     = '>'
+	trace_RECEIVED = '<'
+)
+
+// The placeholder of a password in a transcript
+const trace_REDACTED = "***"
+
+// Tracer writes a transcript of the messages that are exchanged with the
+// server. Every message is a record of a header line, followed by the
+// content of the message and a newline:
+//
+//	> 1 17
+//	sSELECT 1 AS one;
+//	< 1 68
+//	&1 0 1 1 1
+//	...
+//
+// The header holds the direction, '>' for the messages that are sent and '<'
+// for the ones that are received, the number of the connection and the
+// length of the message. The password hash of the login and the passwords in
+// statements are replaced by ***. A Tracer can be shared by several
+// connections.
+type Tracer struct {
+	mu          sync.Mutex
+	w           io.Writer
+	connections int
+}
+
+// NewTracer returns a Tracer that writes the transcript to w
+func NewTracer(w io.Writer) *Tracer {
+	return &Tracer{w: w}
+}
+
+// newConnection returns the number of the next connection in the transcript
+func (t *Tracer) newConnection() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.connections++
+	return t.connections
+}
+
+// record writes a message to the transcript. Errors of the writer are
+// ignored, tracing must not break the connection.
+func (t *Tracer) record(direction byte, conn int, msg []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fmt.Fprintf(t.w, "%c %d %d\n", direction, conn, len(msg))
+	t.w.Write(msg)
+	io.WriteString(t.w, "\n")
+}
+
+// traceSent records a message that is sent to the server
+func (c *mapiConn) traceSent(msg []byte) {
+	if c.Tracer == nil {
+		return
+	}
+	c.Tracer.record(trace_SENT, c.traceId, redact(msg, c.State != mapi_STATE_READY))
+}
+
+// traceReceived records the part of a message that was read from the
+// server. The message is written to the transcript when it is complete.
+func (c *mapiConn) traceReceived(data []byte, complete bool) {
+	if c.Tracer == nil {
+		return
+	}
+	c.traced = append(c.traced, data...)
+	if complete {
+		c.Tracer.record(trace_RECEIVED, c.traceId, c.traced)
+		c.traced = c.traced[:0]
+	}
+}
+
+// passwordPattern matches the passwords of statements like CREATE USER and
+// ALTER USER
+var passwordPattern = regexp.MustCompile(`(?i)(\bPASSWORD\s+)'(?:[^']|'')*'`)
+
+// redact removes the passwords from a message. The login response holds
+// the password hash in its third field.
+func redact(msg []byte, login bool) []byte {
+	if login {
+		t := bytes.SplitN(msg, []byte(":"), 4)
+		if len(t) == 4 {
+			return bytes.Join([][]byte{t[0], t[1], []byte(trace_REDACTED), t[3]}, []byte(":"))
+		}
+		return msg
+	}
+	return passwordPattern.ReplaceAll(msg, []byte("${1}'"+trace_REDACTED+"'"))
+}
+
+// traceRecord is a message of a transcript
+type traceRecord struct {
+	direction byte
+	msg       []byte
+}
+
+// Replay plays the server side of a transcript that was written by a
+// Tracer. Every connection that is opened with the Replay gets the messages
+// of the next connection in the transcript. The messages of the client are
+// compared with the transcript, when they differ the client receives an
+// error.
+type Replay struct {
+	mu          sync.Mutex
+	connections [][]traceRecord
+	err         error
+}
+
+// NewReplay reads a transcript
+func NewReplay(transcript io.Reader) *Replay {
+	r := &Replay{}
+	r.connections, r.err = parseTranscript(transcript)
+	return r
+}
+
+func parseTranscript(transcript io.Reader) ([][]traceRecord, error) {
+	connections := make([][]traceRecord, 0)
+	index := make(map[int]int)
+	br := bufio.NewReader(transcript)
+	for {
+		header, err := br.ReadString('\n')
+		if err == io.EOF && header == "" {
+			return connections, nil
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		t := strings.Fields(header)
+		if len(t) != 3 || len(t[0]) != 1 || (t[0][0] != trace_SENT && t[0][0] != trace_RECEIVED) {
+			return nil, fmt.Errorf("mapi: invalid transcript record: %q", header)
+		}
+		conn, err1 := strconv.Atoi(t[1])
+		length, err2 := strconv.Atoi(t[2])
+		if err1 != nil || err2 != nil || length < 0 {
+			return nil, fmt.Errorf("mapi: invalid transcript record: %q", header)
+		}
+
+		msg := make([]byte, length+1)
+		if _, err := io.ReadFull(br, msg); err != nil || msg[length] != '\n' {
+			return nil, fmt.Errorf("mapi: transcript ends in the middle of a message")
+		}
+		i, ok := index[conn]
+		if !ok {
+			i = len(connections)
+			index[conn] = i
+			connections = append(connections, nil)
+		}
+		connections[i] = append(connections[i], traceRecord{t[0][0], msg[:length]})
+	}
+}
+
+// dial returns a connection to the server side of the next connection of
+// the transcript
+func (r *Replay) dial() (net.Conn, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.connections) == 0 {
+		return nil, fmt.Errorf("mapi: no more connections in the transcript")
+	}
+	records := r.connections[0]
+	r.connections = r.connections[1:]
+
+	client, server := net.Pipe()
+	go replay(server, records)
+	return client, nil
+}
+
+// replay sends the received messages of the transcript to the client, and
+// reads the messages of the client in between
+func replay(conn net.Conn, records []traceRecord) {
+	defer conn.Close()
+	c := &mapiConn{conn: conn}
+	for i := 0; i < len(records); i++ {
+		record := records[i]
+		if record.direction == trace_RECEIVED {
+			if err := c.putBlock(record.msg); err != nil {
+				return
+			}
+			continue
+		}
+
+		msg, err := c.getBlock()
+		if err != nil {
+			return
+		}
+		// The transcript does not tell which message is the login response,
+		// so both ways of redacting are tried
+		actual := redact(msg, false)
+		if bytes.Equal(actual, record.msg) || bytes.Equal(redact(msg, true), record.msg) {
+			continue
+		}
+		c.putBlock([]byte(fmt.Sprintf("!replay: unexpected message %q, the transcript has %q\n", actual, record.msg)))
+		return
+	}
+}
@@ -9,9 +9,13 @@ import (
 	"time"
 )
 
-// Time represents MonetDB's Time datatype.
+// Time represents MonetDB's Time datatype. Nsec is the fraction of the
+// second in nanoseconds, like time.Time. The server keeps microseconds, so a
+// value of a column is a whole number of microseconds, and the digits below
+// a microsecond are dropped when a Time is sent or printed.
 type Time struct {
 	Hour, Min, Sec int
+	Nsec           int
 }
 
 // Time represents MonetDB's Date datatype.
@@ -22,14 +26,17 @@ type Date struct {
 }
 
 // String returns a string representation of a Time
-// in the form "HH:YY:MM".
+// in the form "HH:MM:SS", or "HH:MM:SS.ffffff" when it has a fraction.
 func (t Time) String() string {
+	if t.Nsec/1000 != 0 {
+		return fmt.Sprintf("%02d:%02d:%02d.%06d", t.Hour, t.Min, t.Sec, t.Nsec/1000)
+	}
 	return fmt.Sprintf("%02d:%02d:%02d", t.Hour, t.Min, t.Sec)
 }
 
 // Time converts to time.Time. The date is set to January 1, 1970.
 func (t Time) Time() time.Time {
-	return time.Date(1970, time.January, 1, t.Hour, t.Min, t.Sec, 0, time.UTC)
+	return time.Date(1970, time.January, 1, t.Hour, t.Min, t.Sec, t.Nsec, time.UTC)
 }
 
 // String returns a string representation of a Date
@@ -46,7 +53,7 @@ func (d Date) Time() time.Time {
 // GetTime takes the clock part of a time.Time and put it in a Time
 func GetTime(t time.Time) Time {
 	hour, min, sec := t.Clock()
-	return Time{hour, min, sec}
+	return Time{hour, min, sec, t.Nanosecond()}
 }
 
 // GetDate takes the date part of a time.Time and put it in a Date
@@ -17,7 +17,7 @@ func TestTimeToTime(t *testing.T) {
 	month := time.January
 	day := 1
 
-	v := Time{hour, minute, second}
+	v := Time{hour, minute, second, 0}
 	time := v.Time()
 
 	if time.Hour() != hour {
@@ -116,3 +116,36 @@ func TestDateFromTime(t *testing.T) {
 		t.Errorf("Invalid day: %d, expected: %d", v.Day, day)
 	}
 }
+
+func TestTimeZones(t *testing.T) {
+	t.Run("Verify time with time zone", func(t *testing.T) {
+		v, err := convertToGo("10:20:30.123456+01:30", MDB_TIMETZ)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tm := v.(time.Time)
+		if _, offset := tm.Zone(); offset != 5400 || tm.Year() != 1970 || tm.Nanosecond() != 123456000 {
+			t.Errorf("Unexpected time: %v", tm)
+		}
+		if !tm.Equal(time.Date(1970, time.January, 1, 8, 50, 30, 123456000, time.UTC)) {
+			t.Errorf("Unexpected time: %v", tm)
+		}
+	})
+
+	t.Run("Verify timestamp with time zone in location", func(t *testing.T) {
+		loc := time.FixedZone("EST", -5*3600)
+		r := ResultSet{location: loc}
+		v, err := r.convert("2001-01-02 10:20:30.5+01:00", TableElement{ColumnType: MDB_TIMESTAMPTZ})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tm := v.(time.Time)
+		if tm.Location() != loc || tm.Hour() != 4 || tm.Nanosecond() != 500000000 {
+			t.Errorf("Unexpected timestamp: %v", tm)
+		}
+		v, _ = r.convert("2001-01-02 10:20:30", TableElement{ColumnType: MDB_TIMESTAMP})
+		if v.(time.Time).Location() != time.UTC {
+			t.Errorf("Unexpected timestamp: %v", v)
+		}
+	})
+}
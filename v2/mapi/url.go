@@ -0,0 +1,356 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapi
+
+import (
+	"fmt"
+	"net/url"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The default directory where the server creates its Unix domain socket
+const mapi_DEFAULT_SOCKDIR = "/tmp"
+
+// parseURL handles a DSN in the form of a MonetDB URL, for example
+// monetdbs://hostname:port/database?user=me&password=secret. The monetdbs
+// scheme enables TLS. For backwards compatibility the username and password
+// can also be given in the userinfo part of the URL.
+//
+// The host, port, database, tableschema and table come from the URL itself,
+// they cannot be given as parameters. Parameters with an underscore in their
+// name are implementation specific.
+// The specification requires that unknown parameters of that kind are ignored.
+// Any other unknown parameter is an error.
+func parseURL(name string) (Config, error) {
+	c := DefaultConfig()
+
+	u, err := url.Parse(name)
+	if err != nil {
+		return Config{}, fmt.Errorf("mapi: invalid DSN")
+	}
+
+	c.TLS = u.Scheme == "monetdbs"
+
+	if u.User != nil {
+		c.Username = u.User.Username()
+		c.Password, _ = u.User.Password()
+	}
+
+	hostname := u.Hostname()
+	port := u.Port()
+
+	// The path is /database/tableschema/table, only the database is
+	// relevant for a connection.
+	path := strings.TrimPrefix(u.Path, "/")
+	c.Database, _, _ = Cut(path, "/")
+
+	sockdir := mapi_DEFAULT_SOCKDIR
+	var sockSet bool
+
+	params, err := splitQuery(u.RawQuery)
+	if err != nil {
+		return Config{}, err
+	}
+	for _, p := range params {
+		key, value := p[0], p[1]
+		switch key {
+		case "tls":
+			c.TLS, err = parseBoolParam(key, value)
+		case "host", "port", "database", "tableschema", "table":
+			// The core parameters can only be given in the URL itself
+			err = fmt.Errorf("mapi: parameter %s must be part of the URL", key)
+		case "sock":
+			c.Socket = value
+			sockSet = true
+		case "sockdir":
+			sockdir = value
+		case "cert":
+			c.CertFile = value
+		case "certhash":
+			if _, err = parseCertHash(value); err != nil {
+				err = fmt.Errorf("mapi: invalid value for parameter %s: %s", key, value)
+			}
+			c.CertHash = value
+		case "clientkey":
+			c.ClientKeyFile = value
+		case "clientcert":
+			c.ClientCertFile = value
+		case "user":
+			c.Username = value
+		case "password":
+			c.Password = value
+		case "language":
+			c.Language = value
+		case "autocommit":
+			c.AutoCommit, err = parseBoolParam(key, value)
+		case "sizeheader":
+			c.Sizeheader, err = parseBoolParam(key, value)
+		case "schema":
+			c.Schema = value
+		case "timezone":
+			c.Timezone, err = parseTimezoneParam(key, value)
+		case "replysize", "fetchsize":
+			c.ReplySize, err = parseIntParam(key, value)
+		case "binary":
+			// Binary result sets are not supported, the value is only validated
+			if _, err = parseBoolParam(key, value); err != nil {
+				_, err = parseIntParam(key, value)
+			}
+		case "connect_timeout":
+			c.ConnectTimeout, err = parseSecondsParam(key, value)
+		case "read_timeout":
+			c.ReadTimeout, err = parseSecondsParam(key, value)
+		case "write_timeout":
+			c.WriteTimeout, err = parseSecondsParam(key, value)
+		case "max_redirects":
+			c.MaxRedirects, err = parseIntParam(key, value)
+		case "password_hash":
+			c.PasswordHash = value
+		case "stop_session":
+			c.StopSession, err = parseBoolParam(key, value)
+		case "maxprefetch":
+			_, err = parseIntParam(key, value)
+		case "client_info":
+			_, err = parseBoolParam(key, value)
+		case "client_application", "client_remark":
+			// Only informational, they are not sent to the server
+		default:
+			if !strings.Contains(key, "_") {
+				err = fmt.Errorf("mapi: unknown parameter: %s", key)
+			}
+		}
+		if err != nil {
+			return Config{}, err
+		}
+	}
+
+	if hostname != "" {
+		if strings.Contains(hostname, ":") && !strings.HasPrefix(hostname, "[") {
+			hostname = fmt.Sprintf("[%s]", hostname)
+		}
+		c.Hostname = hostname
+	}
+
+	if port != "" {
+		c.Port, err = strconv.Atoi(port)
+		if err != nil || c.Port < 1 || c.Port > 65535 {
+			return Config{}, fmt.Errorf("mapi: invalid value for parameter port: %s", port)
+		}
+	}
+
+	if c.TLS && sockSet {
+		return Config{}, fmt.Errorf("mapi: parameter sock cannot be used with TLS")
+	}
+	if !c.TLS {
+		for key, value := range map[string]string{
+			"cert":       c.CertFile,
+			"certhash":   c.CertHash,
+			"clientkey":  c.ClientKeyFile,
+			"clientcert": c.ClientCertFile,
+		} {
+			if value != "" {
+				return Config{}, fmt.Errorf("mapi: parameter %s requires TLS", key)
+			}
+		}
+	}
+	if c.ClientCertFile != "" && c.ClientKeyFile == "" {
+		return Config{}, fmt.Errorf("mapi: parameter clientcert requires clientkey")
+	}
+
+	// Like mclient, when no host is given we first try the Unix domain
+	// socket of the server on the local machine before using TCP.
+	if hostname == "" && !sockSet && !c.TLS && runtime.GOOS != "windows" {
+		c.Socket = fmt.Sprintf("%s/.s.monetdb.%d", sockdir, c.Port)
+	}
+
+	return c, nil
+}
+
+// splitQuery splits the query part of the URL into key value pairs, keeping
+// the order. Unlike url.ParseQuery, a plus sign is not decoded into a space.
+func splitQuery(query string) ([][2]string, error) {
+	res := make([][2]string, 0)
+	if query == "" {
+		return res, nil
+	}
+
+	for _, param := range strings.Split(query, "&") {
+		if param == "" {
+			continue
+		}
+		k, v, found := Cut(param, "=")
+		key, err := url.PathUnescape(k)
+		if err != nil {
+			return nil, fmt.Errorf("mapi: invalid parameter name: %s", k)
+		}
+		if !found {
+			return nil, fmt.Errorf("mapi: missing value for parameter %s", key)
+		}
+		value, err := url.PathUnescape(v)
+		if err != nil {
+			return nil, fmt.Errorf("mapi: invalid value for parameter %s: %s", key, v)
+		}
+		res = append(res, [2]string{key, value})
+	}
+	return res, nil
+}
+
+func parseBoolParam(key, value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "true", "yes", "on", "1":
+		return true, nil
+	case "false", "no", "off", "0":
+		return false, nil
+	default:
+		return false, fmt.Errorf("mapi: invalid value for parameter %s: %s", key, value)
+	}
+}
+
+func parseIntParam(key, value string) (int, error) {
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("mapi: invalid value for parameter %s: %s", key, value)
+	}
+	return i, nil
+}
+
+// parseSecondsParam parses a timeout, given as a number of seconds
+func parseSecondsParam(key, value string) (time.Duration, error) {
+	seconds, err := parseIntParam(key, value)
+	return time.Duration(seconds) * time.Second, err
+}
+
+// parseTimezoneParam parses the timezone as the number of minutes east of UTC,
+// as defined by the specification. As an extension, the name of a location in
+// the IANA time zone database is also accepted.
+func parseTimezoneParam(key, value string) (*time.Location, error) {
+	if minutes, err := strconv.Atoi(value); err == nil {
+		return time.FixedZone(formatOffset(minutes*60), minutes*60), nil
+	}
+	if value == "Local" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(value)
+	if err != nil {
+		return nil, fmt.Errorf("mapi: invalid value for parameter %s: %s", key, value)
+	}
+	return loc, nil
+}
+
+// formatOffset returns the offset in seconds in the form +HH:MM
+func formatOffset(offset int) string {
+	sign := '+'
+	if offset < 0 {
+		sign = '-'
+		offset = -offset
+	}
+	return fmt.Sprintf("%c%02d:%02d", sign, offset/3600, (offset%3600)/60)
+}
+
+// formatTimezone is the inverse of parseTimezoneParam
+func formatTimezone(loc *time.Location) string {
+	if _, err := time.LoadLocation(loc.String()); err == nil && loc.String() != "" {
+		return loc.String()
+	}
+	_, offset := time.Now().In(loc).Zone()
+	return strconv.Itoa(offset / 60)
+}
+
+func escapeParam(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
+
+// FormatDSN returns the configuration as a MonetDB URL. Parsing the result
+// with ParseDSN gives back the same configuration, except that timeouts are
+// rounded down to whole seconds. Only the settings that differ from the
+// defaults are included.
+func (c Config) FormatDSN() string {
+	var b strings.Builder
+	d := DefaultConfig()
+
+	if c.TLS {
+		b.WriteString("monetdbs://")
+	} else {
+		b.WriteString("monetdb://")
+	}
+	b.WriteString(c.Hostname)
+	if c.Port != d.Port {
+		fmt.Fprintf(&b, ":%d", c.Port)
+	}
+	b.WriteString("/")
+	b.WriteString(url.PathEscape(c.Database))
+
+	params := make([]string, 0)
+	add := func(key, value string) {
+		params = append(params, key+"="+escapeParam(value))
+	}
+
+	if c.Socket != "" {
+		add("sock", c.Socket)
+	}
+	if c.CertFile != "" {
+		add("cert", c.CertFile)
+	}
+	if c.CertHash != "" {
+		add("certhash", c.CertHash)
+	}
+	if c.ClientKeyFile != "" {
+		add("clientkey", c.ClientKeyFile)
+	}
+	if c.ClientCertFile != "" {
+		add("clientcert", c.ClientCertFile)
+	}
+	if c.Username != "" {
+		add("user", c.Username)
+	}
+	if c.Password != "" {
+		add("password", c.Password)
+	}
+	if c.PasswordHash != "" {
+		add("password_hash", c.PasswordHash)
+	}
+	if c.Language != d.Language {
+		add("language", c.Language)
+	}
+	if c.AutoCommit != d.AutoCommit {
+		add("autocommit", strconv.FormatBool(c.AutoCommit))
+	}
+	if c.Sizeheader != d.Sizeheader {
+		add("sizeheader", strconv.FormatBool(c.Sizeheader))
+	}
+	if c.Schema != "" {
+		add("schema", c.Schema)
+	}
+	if c.Timezone != nil && c.Timezone != d.Timezone {
+		add("timezone", formatTimezone(c.Timezone))
+	}
+	if c.ReplySize != d.ReplySize {
+		add("replysize", strconv.Itoa(c.ReplySize))
+	}
+	if c.ConnectTimeout > 0 {
+		add("connect_timeout", strconv.Itoa(int(c.ConnectTimeout/time.Second)))
+	}
+	if c.ReadTimeout > 0 {
+		add("read_timeout", strconv.Itoa(int(c.ReadTimeout/time.Second)))
+	}
+	if c.WriteTimeout > 0 {
+		add("write_timeout", strconv.Itoa(int(c.WriteTimeout/time.Second)))
+	}
+	if c.MaxRedirects != d.MaxRedirects {
+		add("max_redirects", strconv.Itoa(c.MaxRedirects))
+	}
+	if c.StopSession {
+		add("stop_session", "true")
+	}
+
+	if len(params) > 0 {
+		b.WriteString("?")
+		b.WriteString(strings.Join(params, "&"))
+	}
+	return b.String()
+}
@@ -0,0 +1,204 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapi
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseURLParameters(t *testing.T) {
+	t.Run("Verify session parameters", func(t *testing.T) {
+		c, err := parseDSN("monetdb://localhost/testdb?user=me&password=secret&autocommit=off&replysize=250&sizeheader=false&schema=sys&timezone=-90&connect_timeout=5")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Username != "me" || c.Password != "secret" {
+			t.Errorf("Invalid credentials: %s %s", c.Username, c.Password)
+		}
+		if c.AutoCommit {
+			t.Error("Invalid autocommit, expected false")
+		}
+		if c.ReplySize != 250 {
+			t.Errorf("Invalid replysize: %d, expected: 250", c.ReplySize)
+		}
+		if c.Sizeheader {
+			t.Error("Invalid sizeheader, expected false")
+		}
+		if c.Schema != "sys" {
+			t.Errorf("Invalid schema: %s, expected: sys", c.Schema)
+		}
+		if c.ConnectTimeout != 5*time.Second {
+			t.Errorf("Invalid connect timeout: %s, expected: 5s", c.ConnectTimeout)
+		}
+		_, offset := time.Now().In(c.Timezone).Zone()
+		if offset != -90*60 {
+			t.Errorf("Invalid timezone offset: %d, expected: %d", offset, -90*60)
+		}
+	})
+
+	t.Run("Verify percent decoding", func(t *testing.T) {
+		c, err := parseDSN("monetdb://localhost/test%20db?password=p%40ss%26word+plus&schema=my%2Fschema")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Database != "test db" {
+			t.Errorf("Invalid database: %s", c.Database)
+		}
+		if c.Password != "p@ss&word+plus" {
+			t.Errorf("Invalid password: %s", c.Password)
+		}
+		if c.Schema != "my/schema" {
+			t.Errorf("Invalid schema: %s", c.Schema)
+		}
+	})
+
+	t.Run("Verify host and socket", func(t *testing.T) {
+		c, err := parseDSN("monetdb://:50001/testdb/sys/table1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Database != "testdb" || c.Port != 50001 || c.Hostname != "localhost" {
+			t.Errorf("Invalid endpoint: %s:%d/%s", c.Hostname, c.Port, c.Database)
+		}
+		if runtime.GOOS != "windows" && c.Socket != "/tmp/.s.monetdb.50001" {
+			t.Errorf("Invalid socket: %s", c.Socket)
+		}
+
+		c, err = parseDSN("monetdb://db.example.com/testdb")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Socket != "" {
+			t.Errorf("Unexpected socket: %s", c.Socket)
+		}
+
+		c, err = parseDSN("monetdb://[::1]/testdb?sock=/var/monetdb/.s.monetdb.50000")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Socket != "/var/monetdb/.s.monetdb.50000" || c.Hostname != "[::1]" {
+			t.Errorf("Invalid endpoint: %s %s", c.Socket, c.Hostname)
+		}
+	})
+
+	t.Run("Verify tls parameters", func(t *testing.T) {
+		c, err := parseDSN("monetdb://localhost/testdb?tls=true&certhash=sha256:0123abcd&clientkey=/etc/key.pem")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !c.TLS || c.CertHash != "sha256:0123abcd" || c.ClientKeyFile != "/etc/key.pem" {
+			t.Errorf("Invalid tls settings: %v %s %s", c.TLS, c.CertHash, c.ClientKeyFile)
+		}
+	})
+
+	t.Run("Verify implementation specific parameters are ignored", func(t *testing.T) {
+		_, err := parseDSN("monetdb://localhost/testdb?other_param=1&client_application=test")
+		if err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestParseURLErrors(t *testing.T) {
+	tcs := [][]string{
+		{"monetdb://localhost/testdb?autocommit=maybe", "autocommit"},
+		{"monetdb://localhost/testdb?replysize=many", "replysize"},
+		{"monetdb://localhost/testdb?connect_timeout=soon", "connect_timeout"},
+		{"monetdb://localhost/testdb?read_timeout=soon", "read_timeout"},
+		{"monetdb://localhost/testdb?max_redirects=many", "max_redirects"},
+		{"monetdb://localhost/testdb?timezone=Mars/Olympus", "timezone"},
+		{"monetdb://localhost:0/testdb", "port"},
+		{"monetdb://localhost/testdb?host=db.example.com", "host"},
+		{"monetdb://localhost/testdb?port=50001", "port"},
+		{"monetdb://localhost/testdb?database=other", "database"},
+		{"monetdb://localhost/testdb?tableschema=sys", "tableschema"},
+		{"monetdb://localhost/testdb?table=t", "table"},
+		{"monetdb://localhost:123456/testdb", "port"},
+		{"monetdb://localhost/testdb?unknown=1", "unknown"},
+		{"monetdb://localhost/testdb?schema", "schema"},
+		{"monetdb://localhost/testdb?password=%zz", "password"},
+		{"monetdbs://localhost/testdb?certhash=md5:01", "certhash"},
+		{"monetdbs://localhost/testdb?sock=/tmp/.s.monetdb.50000", "sock"},
+		{"monetdb://localhost/testdb?certhash=sha256:01", "certhash"},
+		{"monetdbs://localhost/testdb?clientcert=/etc/cert.pem", "clientcert"},
+	}
+
+	for _, tc := range tcs {
+		_, err := parseDSN(tc[0])
+		if err == nil {
+			t.Errorf("Error parsing invalid DSN: %s", tc[0])
+		} else if !strings.Contains(err.Error(), tc[1]) {
+			t.Errorf("Error does not name the parameter %s: %v", tc[1], err)
+		}
+	}
+}
+
+func TestFormatDSN(t *testing.T) {
+	amsterdam, err := time.LoadLocation("Europe/Amsterdam")
+	if err != nil {
+		t.Skip("timezone database not available")
+	}
+
+	tcs := []string{
+		"me:secret@localhost:1234/testdb",
+		"me@/tmp/.s.monetdb.50000/testdb",
+		"monetdb://localhost/testdb",
+		"monetdbs://db.example.com:50001/test%20db?certhash=sha256:abcd&user=me&password=p%40ss%2Bword",
+		"monetdb://[::1]/testdb?autocommit=false&sizeheader=false&replysize=-1&schema=sys&timezone=120",
+		"monetdb:///testdb",
+		"monetdb://localhost/testdb?connect_timeout=30",
+		"monetdb://localhost/testdb?stop_session=true",
+		"monetdb://localhost/testdb?read_timeout=10&write_timeout=20&max_redirects=3",
+		"monetdb://localhost/testdb?user=me&password_hash=sha512:abcd",
+	}
+
+	for _, tc := range tcs {
+		c, err := parseDSN(tc)
+		if err != nil {
+			t.Errorf("Error parsing DSN: %s -> %v", tc, err)
+			continue
+		}
+		dsn := c.FormatDSN()
+		r, err := parseDSN(dsn)
+		if err != nil {
+			t.Errorf("Error parsing formatted DSN: %s -> %v", dsn, err)
+			continue
+		}
+		if c.Timezone.String() != r.Timezone.String() {
+			t.Errorf("Timezone changed in round trip: %s -> %s", tc, dsn)
+		}
+		c.Timezone, r.Timezone = nil, nil
+		if !reflect.DeepEqual(c, r) {
+			t.Errorf("Config changed in round trip: %s -> %s\n%+v\n%+v", tc, dsn, c, r)
+		}
+	}
+
+	c := DefaultConfig()
+	c.Timezone = amsterdam
+	r, err := parseDSN(c.FormatDSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Timezone.String() != "Europe/Amsterdam" {
+		t.Errorf("Invalid timezone: %s", r.Timezone)
+	}
+
+	c = DefaultConfig()
+	c.ReadTimeout = 10 * time.Second
+	c.WriteTimeout = 20 * time.Second
+	c.MaxRedirects = 0
+	c.PasswordHash = "sha512:abcd"
+	r, err = parseDSN(c.FormatDSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.ReadTimeout != c.ReadTimeout || r.WriteTimeout != c.WriteTimeout || r.MaxRedirects != 0 || r.PasswordHash != c.PasswordHash {
+		t.Errorf("Config changed in round trip: %s\n%+v", c.FormatDSN(), r)
+	}
+}
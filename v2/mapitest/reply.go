@@ -0,0 +1,189 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapitest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Reply is the answer of the server to a statement. A nil Reply sends an
+// empty answer.
+type Reply interface {
+	writeTo(sess *session, b *strings.Builder)
+}
+
+// Raw is sent to the client as it is. The lines must end with a newline.
+type Raw string
+
+func (r Raw) writeTo(sess *session, b *strings.Builder) {
+	b.WriteString(string(r))
+}
+
+// Info is a message that is sent before the result, like a warning
+type Info string
+
+func (i Info) writeTo(sess *session, b *strings.Builder) {
+	fmt.Fprintf(b, "#%s\n", i)
+}
+
+// Error is an error of the statement. Without a Code the error is sent
+// without a SQLSTATE.
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e Error) writeTo(sess *session, b *strings.Builder) {
+	if e.Code == "" {
+		fmt.Fprintf(b, "!%s\n", e.Message)
+	} else {
+		fmt.Fprintf(b, "!%s!%s\n", e.Code, e.Message)
+	}
+}
+
+// Update is the result of a statement that changes rows. LastID is the
+// last generated key, or -1.
+type Update struct {
+	Count  int
+	LastID int
+}
+
+func (u Update) writeTo(sess *session, b *strings.Builder) {
+	fmt.Fprintf(b, "&2 %d %d\n", u.Count, u.LastID)
+}
+
+// Schema is the result of a statement that changes the schema, like CREATE
+// TABLE
+type Schema struct{}
+
+func (Schema) writeTo(sess *session, b *strings.Builder) {
+	b.WriteString("&3\n")
+}
+
+// Transaction is the result of START TRANSACTION, COMMIT and ROLLBACK. It
+// holds the autocommit state after the statement.
+type Transaction struct {
+	AutoCommit bool
+}
+
+func (t Transaction) writeTo(sess *session, b *strings.Builder) {
+	if t.AutoCommit {
+		b.WriteString("&4 t\n")
+	} else {
+		b.WriteString("&4 f\n")
+	}
+}
+
+// Table is a result set. The rows hold the values as the server formats
+// them, like 1, "text" or NULL. When there are more rows than the reply size
+// of the session, the client fetches the next blocks with Xexport.
+type Table struct {
+	// Name is the name of the table of the columns, the default is sys.t
+	Name    string
+	Columns []string
+	Types   []string
+	Rows    [][]string
+}
+
+func (t Table) writeTo(sess *session, b *strings.Builder) {
+	sess.nextTable++
+	id := sess.nextTable
+
+	count := len(t.Rows)
+	if sess.replySize > 0 && count > sess.replySize {
+		count = sess.replySize
+		sess.tables[id] = t
+	}
+
+	name := t.Name
+	if name == "" {
+		name = "sys.t"
+	}
+	names := make([]string, len(t.Columns))
+	lengths := make([]string, len(t.Columns))
+	for i := range t.Columns {
+		names[i] = name
+		length := 0
+		for _, row := range t.Rows {
+			if i < len(row) && len(row[i]) > length {
+				length = len(row[i])
+			}
+		}
+		lengths[i] = fmt.Sprint(length)
+	}
+
+	fmt.Fprintf(b, "&1 %d %d %d %d\n", id, len(t.Rows), len(t.Columns), count)
+	fmt.Fprintf(b, "%% %s # table_name\n", strings.Join(names, ",\t"))
+	fmt.Fprintf(b, "%% %s # name\n", strings.Join(t.Columns, ",\t"))
+	fmt.Fprintf(b, "%% %s # type\n", strings.Join(t.Types, ",\t"))
+	fmt.Fprintf(b, "%% %s # length\n", strings.Join(lengths, ",\t"))
+	t.writeRows(b, 0, count)
+}
+
+// writeBlock writes the rows of an Xexport command
+func (t Table) writeBlock(b *strings.Builder, id int, offset int, amount int) {
+	if offset > len(t.Rows) {
+		offset = len(t.Rows)
+	}
+	if offset+amount > len(t.Rows) {
+		amount = len(t.Rows) - offset
+	}
+	fmt.Fprintf(b, "&6 %d %d %d %d\n", id, len(t.Columns), amount, offset)
+	t.writeRows(b, offset, amount)
+}
+
+func (t Table) writeRows(b *strings.Builder, offset int, amount int) {
+	for _, row := range t.Rows[offset : offset+amount] {
+		fmt.Fprintf(b, "[ %s\t]\n", strings.Join(row, ",\t"))
+	}
+}
+
+// Prepared is the result of PREPARE. Params holds the types of the
+// placeholders, like int, varchar(20) or decimal(10,2). The statement is
+// executed with EXEC and the ID, which is passed on to the Handler like any
+// other statement.
+type Prepared struct {
+	ID     int
+	Params []string
+}
+
+func (p Prepared) writeTo(sess *session, b *strings.Builder) {
+	fmt.Fprintf(b, "&5 %d %d 6 %d\n", p.ID, len(p.Params), len(p.Params))
+	b.WriteString("% .prepare,\t.prepare,\t.prepare,\t.prepare,\t.prepare,\t.prepare # table_name\n")
+	b.WriteString("% type,\tdigits,\tscale,\tschema,\ttable,\tcolumn # name\n")
+	b.WriteString("% varchar,\tint,\tint,\tstr,\tstr,\tstr # type\n")
+	b.WriteString("% 7,\t2,\t1,\t0,\t0,\t0 # length\n")
+	for _, param := range p.Params {
+		name, digits, scale := param, "0", "0"
+		if i := strings.IndexByte(param, '('); i >= 0 && strings.HasSuffix(param, ")") {
+			name = param[:i]
+			digits = param[i+1 : len(param)-1]
+			if j := strings.IndexByte(digits, ','); j >= 0 {
+				digits, scale = digits[:j], digits[j+1:]
+			}
+		}
+		fmt.Fprintf(b, "[ \"%s\",\t%s,\t%s,\tNULL,\tNULL,\tNULL\t]\n", name, digits, scale)
+	}
+}
+
+// Hangup closes the connection instead of answering the statement, like a
+// server that crashes or a network that fails
+type Hangup struct{}
+
+func (Hangup) writeTo(sess *session, b *strings.Builder) {
+	sess.hangup = true
+}
+
+// Replies is the answer to a statement that holds several queries
+type Replies []Reply
+
+func (r Replies) writeTo(sess *session, b *strings.Builder) {
+	for _, reply := range r {
+		if reply != nil {
+			reply.writeTo(sess, b)
+		}
+	}
+}
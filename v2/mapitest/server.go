@@ -0,0 +1,476 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+/*
+Package mapitest provides a fake MonetDB server for tests, which speaks the
+MAPI protocol on a local TCP port. The statements of the clients are answered
+by a Handler, so the behaviour of the driver can be tested without a database:
+
+	srv := mapitest.NewServer(mapitest.HandlerFunc(func(r *mapitest.Request) mapitest.Reply {
+		return mapitest.Table{
+			Columns: []string{"id"},
+			Types:   []string{"int"},
+			Rows:    [][]string{{"1"}, {"2"}},
+		}
+	}))
+	defer srv.Close()
+
+	db, err := sql.Open("monetdb", srv.DSN())
+
+The server handles the login, redirects, the session settings and fetching
+the next block of a result set with Xexport. The session id and sys.stop are
+supported, so a statement can be interrupted when the context of the client
+is cancelled, if the DSN has stop_session=true.
+*/
+package mapitest
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The maximum size of a block, a longer message is split in several blocks
+const maxBlockSize = 8190
+
+// The reply size of a session when the client does not set it
+const defaultReplySize = 100
+
+// The salt of the login challenge
+const salt = "mapitest"
+
+// Request is a statement that a client sent to the server
+type Request struct {
+	// Statement is the SQL statement, without the trailing semicolon
+	Statement string
+	// Session is the id of the session of the client
+	Session int
+
+	ctx context.Context
+}
+
+// Context returns the context of the request. It is cancelled when the
+// client disconnects, or when the statement is stopped with sys.stop.
+func (r *Request) Context() context.Context {
+	return r.ctx
+}
+
+// Handler answers the statements of the clients. It is called from the
+// goroutines of the sessions, so it must be safe for concurrent use.
+type Handler interface {
+	ServeMAPI(r *Request) Reply
+}
+
+// HandlerFunc is a function that is used as Handler
+type HandlerFunc func(r *Request) Reply
+
+func (f HandlerFunc) ServeMAPI(r *Request) Reply {
+	return f(r)
+}
+
+// Server is a fake MonetDB server
+type Server struct {
+	// Handler answers the statements
+	Handler Handler
+	// User and Password, when set, are checked at the login
+	User     string
+	Password string
+	// Redirects are sent as answer to the first logins, one for every login,
+	// for example "mapi:merovingian://proxy" or
+	// "mapi:monetdb://localhost:50001/demo"
+	Redirects []string
+
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	mu          sync.Mutex
+	conns       map[net.Conn]bool
+	sessions    map[int]*session
+	nextSession int
+	redirects   int
+	statements  []string
+	commands    []string
+}
+
+// NewServer starts a server that answers the statements with the handler
+func NewServer(handler Handler) *Server {
+	s := NewUnstartedServer(handler)
+	s.Start()
+	return s
+}
+
+// NewUnstartedServer returns a server that is not started, so its settings
+// can be changed before Start is called
+func NewUnstartedServer(handler Handler) *Server {
+	return &Server{Handler: handler}
+}
+
+// Start listens on a free local port and serves the clients
+func (s *Server) Start() {
+	if s.listener != nil {
+		panic("mapitest: server already started")
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("mapitest: failed to listen: %v", err))
+	}
+	s.listener = l
+	s.conns = make(map[net.Conn]bool)
+	s.sessions = make(map[int]*session)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns[conn] = true
+			s.mu.Unlock()
+
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				s.serve(conn)
+			}()
+		}
+	}()
+}
+
+// Close stops the server and closes the connections of the clients. It
+// waits until the handlers have returned.
+func (s *Server) Close() {
+	s.listener.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	for _, sess := range s.sessions {
+		sess.cancel()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// Addr returns the address that the server listens on, as host:port
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Port returns the port that the server listens on
+func (s *Server) Port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// DSN returns a data source name to connect to the server. It is a URL, so
+// parameters can be added, like "?replysize=10"
+func (s *Server) DSN() string {
+	user, password := s.User, s.Password
+	if user == "" {
+		user = "monetdb"
+	}
+	if password == "" {
+		password = "monetdb"
+	}
+	return fmt.Sprintf("monetdb://%s:%s@%s/demo", user, password, s.Addr())
+}
+
+// Statements returns the SQL statements that the server received
+func (s *Server) Statements() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.statements...)
+}
+
+// Commands returns the other commands that the server received, like
+// "reply_size 100" or "export 1 100 100"
+func (s *Server) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+// session is the state of the connection of a client
+type session struct {
+	id        int
+	conn      net.Conn
+	replySize int
+	tables    map[int]Table
+	nextTable int
+	// hangup closes the connection instead of sending the reply
+	hangup bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	// stop cancels the statement that is running
+	stop context.CancelFunc
+}
+
+func (s *Server) serve(conn net.Conn) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.mu.Lock()
+	s.nextSession++
+	sess := &session{
+		id:        s.nextSession,
+		conn:      conn,
+		replySize: defaultReplySize,
+		tables:    make(map[int]Table),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+	s.sessions[sess.id] = sess
+	s.mu.Unlock()
+
+	defer func() {
+		cancel()
+		conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		delete(s.sessions, sess.id)
+		s.mu.Unlock()
+	}()
+
+	if err := s.login(sess); err != nil {
+		return
+	}
+	for {
+		msg, err := readMessage(conn)
+		if err != nil {
+			return
+		}
+		response := s.handle(sess, msg)
+		if sess.hangup {
+			return
+		}
+		if err := writeMessage(conn, response); err != nil {
+			return
+		}
+	}
+}
+
+// login sends challenges until the client logs in, or a redirect to
+// another server is sent
+func (s *Server) login(sess *session) error {
+	for {
+		// The handshake options are offered, so the session settings are
+		// sent with the login
+		challenge := fmt.Sprintf("%s:mserver:9:SHA1,MD5:LIT:SHA512:sql=6:", salt)
+		if err := writeMessage(sess.conn, challenge); err != nil {
+			return err
+		}
+		msg, err := readMessage(sess.conn)
+		if err != nil {
+			return err
+		}
+
+		s.mu.Lock()
+		var redirect string
+		if s.redirects < len(s.Redirects) {
+			redirect = s.Redirects[s.redirects]
+			s.redirects++
+		}
+		s.mu.Unlock()
+		if redirect != "" {
+			if err := writeMessage(sess.conn, "^"+redirect+"\n"); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := s.checkLogin(sess, string(msg)); err != nil {
+			writeMessage(sess.conn, fmt.Sprintf("!InvalidCredentialsException:checkCredentials:%s\n", err))
+			return err
+		}
+		return writeMessage(sess.conn, "")
+	}
+}
+
+// checkLogin checks the login response of the client, which has the form
+// BIG:user:{SHA1}hash:sql:database:FILETRANS:options:
+func (s *Server) checkLogin(sess *session, response string) error {
+	t := strings.Split(response, ":")
+	if len(t) < 5 {
+		return fmt.Errorf("invalid login response")
+	}
+	if s.User != "" && t[1] != s.User {
+		return fmt.Errorf("invalid credentials for user '%s'", t[1])
+	}
+	if s.Password != "" && t[2] != passwordHash(s.Password) {
+		return fmt.Errorf("invalid credentials for user '%s'", t[1])
+	}
+	for _, field := range t[5:] {
+		for _, option := range strings.Split(field, ",") {
+			if name, value, found := strings.Cut(option, "="); found && name == "reply_size" {
+				sess.replySize, _ = strconv.Atoi(value)
+			}
+		}
+	}
+	return nil
+}
+
+// passwordHash returns the hash of the password that the client sends with
+// the algorithms of the challenge
+func passwordHash(password string) string {
+	digest := sha512.Sum512([]byte(password))
+	h := sha1.New()
+	io.WriteString(h, hex.EncodeToString(digest[:]))
+	io.WriteString(h, salt)
+	return fmt.Sprintf("{SHA1}%x", h.Sum(nil))
+}
+
+// handle returns the answer to a message of the client
+func (s *Server) handle(sess *session, msg []byte) string {
+	if len(msg) == 0 {
+		return ""
+	}
+	switch msg[0] {
+	case 's':
+		statement := strings.TrimSpace(strings.TrimRight(string(msg[1:]), "; \t\n"))
+		s.mu.Lock()
+		s.statements = append(s.statements, statement)
+		s.mu.Unlock()
+		return s.execute(sess, statement)
+	case 'X':
+		command := string(msg[1:])
+		s.mu.Lock()
+		s.commands = append(s.commands, command)
+		s.mu.Unlock()
+		return sess.command(command)
+	default:
+		return fmt.Sprintf("!42000!unknown language: %q\n", msg[0])
+	}
+}
+
+// execute answers a statement. Asking for the session id and stopping a
+// session are handled by the server itself.
+func (s *Server) execute(sess *session, statement string) string {
+	if strings.EqualFold(statement, "SELECT sys.current_sessionid()") {
+		return sess.reply(Table{
+			Columns: []string{"%1"},
+			Types:   []string{"int"},
+			Rows:    [][]string{{strconv.Itoa(sess.id)}},
+		})
+	}
+	var id int
+	if n, _ := fmt.Sscanf(statement, "CALL sys.stop(%d)", &id); n == 1 {
+		s.mu.Lock()
+		if target, ok := s.sessions[id]; ok && target.stop != nil {
+			target.stop()
+		}
+		s.mu.Unlock()
+		return "&3\n"
+	}
+
+	ctx, stop := context.WithCancel(sess.ctx)
+	defer stop()
+	s.mu.Lock()
+	sess.stop = stop
+	s.mu.Unlock()
+
+	var reply Reply
+	if s.Handler != nil {
+		reply = s.Handler.ServeMAPI(&Request{Statement: statement, Session: sess.id, ctx: ctx})
+	}
+
+	s.mu.Lock()
+	sess.stop = nil
+	s.mu.Unlock()
+	return sess.reply(reply)
+}
+
+// reply formats the reply of a statement
+func (sess *session) reply(reply Reply) string {
+	if reply == nil {
+		return ""
+	}
+	var b strings.Builder
+	reply.writeTo(sess, &b)
+	return b.String()
+}
+
+// command answers a command, like reply_size or export
+func (sess *session) command(command string) string {
+	t := strings.Fields(command)
+	if len(t) == 0 {
+		return ""
+	}
+	args := make([]int, len(t)-1)
+	for i, arg := range t[1:] {
+		args[i], _ = strconv.Atoi(arg)
+	}
+
+	switch t[0] {
+	case "reply_size":
+		if len(args) == 1 {
+			sess.replySize = args[0]
+		}
+	case "export":
+		if len(args) != 3 {
+			return "!42000!export: invalid arguments\n"
+		}
+		table, ok := sess.tables[args[0]]
+		if !ok {
+			return fmt.Sprintf("!42000!export: result set %d not found\n", args[0])
+		}
+		var b strings.Builder
+		table.writeBlock(&b, args[0], args[1], args[2])
+		return b.String()
+	case "close":
+		if len(args) == 1 {
+			delete(sess.tables, args[0])
+		}
+	}
+	return ""
+}
+
+// readMessage reads a complete message of the client
+func readMessage(conn net.Conn) ([]byte, error) {
+	msg := make([]byte, 0)
+	for {
+		var flag uint16
+		if err := binary.Read(conn, binary.LittleEndian, &flag); err != nil {
+			return nil, err
+		}
+		data := make([]byte, flag>>1)
+		if _, err := io.ReadFull(conn, data); err != nil {
+			return nil, err
+		}
+		msg = append(msg, data...)
+		if flag&1 == 1 {
+			return msg, nil
+		}
+	}
+}
+
+// writeMessage sends a message to the client, in blocks of the maximum size
+func writeMessage(conn net.Conn, msg string) error {
+	for {
+		size := len(msg)
+		last := 1
+		if size > maxBlockSize {
+			size = maxBlockSize
+			last = 0
+		}
+		if err := binary.Write(conn, binary.LittleEndian, uint16(size<<1|last)); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(conn, msg[:size]); err != nil {
+			return err
+		}
+		msg = msg[size:]
+		if last == 1 {
+			return nil
+		}
+	}
+}
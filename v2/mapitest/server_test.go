@@ -0,0 +1,190 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package mapitest_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/MonetDB/MonetDB-Go/v2"
+	"github.com/MonetDB/MonetDB-Go/v2/mapi"
+	"github.com/MonetDB/MonetDB-Go/v2/mapitest"
+)
+
+// numbers returns a table with the numbers from 0 to n
+func numbers(n int) mapitest.Table {
+	rows := make([][]string, n)
+	for i := range rows {
+		rows[i] = []string{fmt.Sprint(i), fmt.Sprintf("\"name%d\"", i)}
+	}
+	return mapitest.Table{
+		Columns: []string{"id", "name"},
+		Types:   []string{"int", "varchar"},
+		Rows:    rows,
+	}
+}
+
+func openDB(t *testing.T, dsn string) *sql.DB {
+	db, err := sql.Open("monetdb", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestServer(t *testing.T) {
+	t.Run("Verify rows are fetched in blocks", func(t *testing.T) {
+		srv := mapitest.NewServer(mapitest.HandlerFunc(func(r *mapitest.Request) mapitest.Reply {
+			return numbers(5)
+		}))
+		defer srv.Close()
+		db := openDB(t, srv.DSN()+"?replysize=2")
+
+		rows, err := db.Query("SELECT id, name FROM t")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		n := 0
+		for rows.Next() {
+			var id int
+			var name string
+			if err := rows.Scan(&id, &name); err != nil {
+				t.Fatal(err)
+			}
+			if id != n || name != fmt.Sprintf("name%d", n) {
+				t.Errorf("Unexpected row %d: %d, %s", n, id, name)
+			}
+			n++
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if n != 5 {
+			t.Errorf("Unexpected number of rows: %d", n)
+		}
+
+		// The offset and amount of the blocks that were fetched
+		var exports []string
+		for _, cmd := range srv.Commands() {
+			if t := strings.Fields(cmd); t[0] == "export" {
+				exports = append(exports, strings.Join(t[2:], " "))
+			}
+		}
+		if strings.Join(exports, ";") != "2 2;4 1" {
+			t.Errorf("Unexpected commands: %q", exports)
+		}
+	})
+
+	t.Run("Verify statements and results", func(t *testing.T) {
+		srv := mapitest.NewServer(mapitest.HandlerFunc(func(r *mapitest.Request) mapitest.Reply {
+			return mapitest.Update{Count: 3, LastID: -1}
+		}))
+		defer srv.Close()
+		db := openDB(t, srv.DSN())
+
+		res, err := db.Exec("DELETE FROM t")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n, _ := res.RowsAffected(); n != 3 {
+			t.Errorf("Unexpected number of rows: %d", n)
+		}
+		statements := srv.Statements()
+		if statements[len(statements)-1] != "DELETE FROM t" {
+			t.Errorf("Unexpected statements: %q", statements)
+		}
+	})
+
+	t.Run("Verify server errors", func(t *testing.T) {
+		srv := mapitest.NewServer(mapitest.HandlerFunc(func(r *mapitest.Request) mapitest.Reply {
+			return mapitest.Error{Code: "42S02", Message: "SELECT: no such table 't'"}
+		}))
+		defer srv.Close()
+		db := openDB(t, srv.DSN())
+
+		_, err := db.Exec("SELECT * FROM t")
+		if !errors.Is(err, mapi.ErrMissingObject) {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Verify wrong password is refused", func(t *testing.T) {
+		srv := mapitest.NewUnstartedServer(nil)
+		srv.Password = "secret"
+		srv.Start()
+		defer srv.Close()
+
+		db := openDB(t, "monetdb://monetdb:wrong@"+srv.Addr()+"/demo")
+		if err := db.Ping(); err == nil || !strings.Contains(err.Error(), "invalid credentials") {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if err := openDB(t, srv.DSN()).Ping(); err != nil {
+			t.Errorf("Login failed: %v", err)
+		}
+	})
+
+	t.Run("Verify redirects are followed", func(t *testing.T) {
+		target := mapitest.NewServer(mapitest.HandlerFunc(func(r *mapitest.Request) mapitest.Reply {
+			return numbers(1)
+		}))
+		defer target.Close()
+		proxy := mapitest.NewUnstartedServer(nil)
+		proxy.Redirects = []string{
+			"mapi:merovingian://proxy",
+			fmt.Sprintf("mapi:monetdb://127.0.0.1:%d/demo", target.Port()),
+		}
+		proxy.Start()
+		defer proxy.Close()
+		db := openDB(t, proxy.DSN())
+
+		var id int
+		var name string
+		if err := db.QueryRow("SELECT id, name FROM t").Scan(&id, &name); err != nil {
+			t.Fatal(err)
+		}
+		if len(target.Statements()) == 0 {
+			t.Error("The statement is not sent to the target of the redirect")
+		}
+	})
+
+	t.Run("Verify cancelled statement is stopped", func(t *testing.T) {
+		stopped := make(chan struct{})
+		srv := mapitest.NewServer(mapitest.HandlerFunc(func(r *mapitest.Request) mapitest.Reply {
+			if !strings.Contains(r.Statement, "sys.sleep") {
+				return mapitest.Update{Count: 1, LastID: -1}
+			}
+			<-r.Context().Done()
+			close(stopped)
+			return mapitest.Error{Code: "HY008", Message: "Query aborted"}
+		}))
+		defer srv.Close()
+		db := openDB(t, srv.DSN()+"?stop_session=true")
+		if err := db.Ping(); err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err := db.ExecContext(ctx, "SELECT sys.sleep(10000)")
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		select {
+		case <-stopped:
+		case <-time.After(time.Second):
+			t.Error("The statement is not stopped")
+		}
+		if err := db.Ping(); err != nil {
+			t.Errorf("The connection is not usable: %v", err)
+		}
+	})
+}
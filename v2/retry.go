@@ -0,0 +1,126 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package monetdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/MonetDB/MonetDB-Go/v2/mapi"
+)
+
+// The defaults of RunInTx, the waiting time doubles after every conflict
+const (
+	defaultRetryAttempts       = 5
+	defaultRetryInitialBackoff = 10 * time.Millisecond
+	defaultRetryMaxBackoff     = time.Second
+)
+
+type retryConfig struct {
+	attempts       int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+}
+
+type retryOption func(*retryConfig)
+
+// MaxAttemptsOption sets the number of times that RunInTx runs the
+// transaction, including the first time. The default is 5.
+func MaxAttemptsOption(attempts int) retryOption {
+	return func(c *retryConfig) {
+		c.attempts = attempts
+	}
+}
+
+// BackoffOption sets the time that RunInTx waits before it runs the
+// transaction again. The time starts at initial and doubles after every
+// conflict, until it reaches max. A random part of it is left out, so
+// conflicting clients do not retry at the same moment. The default is 10ms up
+// to 1s.
+func BackoffOption(initial time.Duration, max time.Duration) retryOption {
+	return func(c *retryConfig) {
+		c.initialBackoff = initial
+		c.maxBackoff = max
+	}
+}
+
+// RunInTx runs fn in a transaction and commits it. When fn returns an error,
+// the transaction is rolled back and the error is returned.
+//
+// MonetDB uses optimistic concurrency control. When the transaction
+// conflicts with another one, the server aborts it, usually at the commit.
+// RunInTx then runs fn again in a new transaction, until it succeeds or the
+// number of attempts is reached. The conflicts are detected with
+// mapi.ErrTransactionConflict. As fn may run several times, it should not
+// have side effects outside of the transaction.
+func RunInTx(ctx context.Context, db *sql.DB, opts *sql.TxOptions, fn func(*sql.Tx) error, options ...retryOption) error {
+	cfg := retryConfig{
+		attempts:       defaultRetryAttempts,
+		initialBackoff: defaultRetryInitialBackoff,
+		maxBackoff:     defaultRetryMaxBackoff,
+	}
+	for _, opt := range options {
+		opt(&cfg)
+	}
+
+	backoff := cfg.initialBackoff
+	for attempt := 1; ; attempt++ {
+		err := runTx(ctx, db, opts, fn)
+		if err == nil || !errors.Is(err, mapi.ErrTransactionConflict) {
+			return err
+		}
+		if attempt >= cfg.attempts {
+			return fmt.Errorf("monetdb: transaction failed after %d attempts: %w", attempt, err)
+		}
+
+		if err := sleepContext(ctx, jitter(backoff)); err != nil {
+			return err
+		}
+		backoff *= 2
+		if backoff > cfg.maxBackoff {
+			backoff = cfg.maxBackoff
+		}
+	}
+}
+
+// runTx runs fn in a single transaction
+func runTx(ctx context.Context, db *sql.DB, opts *sql.TxOptions, fn func(*sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	// When the commit fails, the server has already rolled back the
+	// transaction
+	return tx.Commit()
+}
+
+// jitter returns a random duration between half of d and d
+func jitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)))
+}
+
+// sleepContext waits for d, or until the context is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
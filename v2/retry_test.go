@@ -0,0 +1,144 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package monetdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MonetDB/MonetDB-Go/v2/mapi"
+	"github.com/MonetDB/MonetDB-Go/v2/mapitest"
+)
+
+const conflictError = "COMMIT: transaction is aborted because of concurrency conflicts, will ROLLBACK instead"
+
+// conflictingServer answers the first COMMIT statements with a conflict
+func conflictingServer(t *testing.T, conflicts int) *mapitest.Server {
+	var mu sync.Mutex
+	srv := mapitest.NewServer(mapitest.HandlerFunc(func(r *mapitest.Request) mapitest.Reply {
+		switch {
+		case strings.HasPrefix(r.Statement, "START TRANSACTION"), r.Statement == "ROLLBACK":
+			return mapitest.Transaction{AutoCommit: false}
+		case r.Statement == "COMMIT":
+			mu.Lock()
+			defer mu.Unlock()
+			if conflicts > 0 {
+				conflicts--
+				return mapitest.Error{Code: "40000", Message: conflictError}
+			}
+			return mapitest.Transaction{AutoCommit: true}
+		default:
+			return mapitest.Update{Count: 1, LastID: -1}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func insert(tx *sql.Tx) error {
+	_, err := tx.Exec("INSERT INTO t VALUES (1)")
+	return err
+}
+
+func countStatements(statements []string, statement string) int {
+	n := 0
+	for _, s := range statements {
+		if s == statement {
+			n++
+		}
+	}
+	return n
+}
+
+func TestRunInTx(t *testing.T) {
+	t.Run("Verify transaction is retried after a conflict", func(t *testing.T) {
+		srv := conflictingServer(t, 2)
+		db := openPool(t, srv)
+
+		runs := 0
+		err := RunInTx(context.Background(), db, nil, func(tx *sql.Tx) error {
+			runs++
+			return insert(tx)
+		}, BackoffOption(time.Millisecond, time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if runs != 3 {
+			t.Errorf("Unexpected number of runs: %d", runs)
+		}
+		if n := countStatements(srv.Statements(), "COMMIT"); n != 3 {
+			t.Errorf("Unexpected number of commits: %d", n)
+		}
+	})
+
+	t.Run("Verify number of attempts is limited", func(t *testing.T) {
+		srv := conflictingServer(t, 10)
+		db := openPool(t, srv)
+
+		runs := 0
+		err := RunInTx(context.Background(), db, nil, func(tx *sql.Tx) error {
+			runs++
+			return insert(tx)
+		}, MaxAttemptsOption(3), BackoffOption(time.Millisecond, time.Millisecond))
+		if !errors.Is(err, mapi.ErrTransactionConflict) {
+			t.Fatalf("Expected a transaction conflict, got %v", err)
+		}
+		if mapi.SQLState(err) != "40000" {
+			t.Errorf("Unexpected SQLSTATE: %s", mapi.SQLState(err))
+		}
+		if runs != 3 {
+			t.Errorf("Unexpected number of runs: %d", runs)
+		}
+	})
+
+	t.Run("Verify other errors are not retried", func(t *testing.T) {
+		srv := conflictingServer(t, 0)
+		db := openPool(t, srv)
+
+		failure := errors.New("failure")
+		runs := 0
+		err := RunInTx(context.Background(), db, nil, func(tx *sql.Tx) error {
+			runs++
+			if err := insert(tx); err != nil {
+				return err
+			}
+			return failure
+		})
+		if err != failure {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if runs != 1 {
+			t.Errorf("Unexpected number of runs: %d", runs)
+		}
+		history := srv.Statements()
+		if countStatements(history, "ROLLBACK") != 1 || countStatements(history, "COMMIT") != 0 {
+			t.Errorf("Transaction is not rolled back: %q", history)
+		}
+	})
+
+	t.Run("Verify waiting stops when the context is done", func(t *testing.T) {
+		srv := conflictingServer(t, 10)
+		db := openPool(t, srv)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		runs := 0
+		err := RunInTx(ctx, db, nil, func(tx *sql.Tx) error {
+			runs++
+			return insert(tx)
+		}, BackoffOption(time.Hour, time.Hour))
+		if err != context.DeadlineExceeded {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if runs != 1 {
+			t.Errorf("Unexpected number of runs: %d", runs)
+		}
+	})
+}
@@ -5,42 +5,53 @@
 package monetdb
 
 import (
+	"context"
 	"database/sql/driver"
+	"encoding/json"
 	"fmt"
-	"io"
 	"math"
+	"net/netip"
+	"net/url"
 	"strings"
 	"reflect"
 	"time"
-	"context"
 
 	"github.com/MonetDB/MonetDB-Go/v2/mapi"
 )
 
 type Rows struct {
+	conn        *Conn
 	query       mapi.Query
 	active      bool
-	rowNum      int
-	rows        [][]driver.Value
+	// ctx is the context of the query, it also limits reading the rows
+	ctx         context.Context
 }
 
-func newRows(q mapi.Query) *Rows {
+func newRows(ctx context.Context, c *Conn, q mapi.Query) *Rows {
 	return &Rows{
+		conn:    c,
 		query:   q,
 		active:  true,
-		rowNum:  0,
+		ctx:     ctx,
 	}
 }
 
+// Close discards the rows that are not read yet, so the connection can be
+// used for the next statement
 func (r *Rows) Close() error {
+	if !r.active {
+		return nil
+	}
 	r.active = false
-	return nil
+	return r.query.Close()
 }
 
 func (r *Rows) Columns() []string {
 	return r.query.Result().Columns()
 }
 
+// Next reads the rows while they arrive from the server, the next block of
+// rows is fetched when the current one is read
 func (r *Rows) Next(dest []driver.Value) error {
 	if !r.active {
 		return fmt.Errorf("monetdb: rows closed")
@@ -49,79 +60,68 @@ func (r *Rows) Next(dest []driver.Value) error {
 		return fmt.Errorf("monetdb: query didn't result in a resultset")
 	}
 
-	if r.rowNum >= r.query.Result().Metadata.RowCount {
-		return io.EOF
+	row, err := r.mapiDo(r.query.NextRow)
+	if err != nil {
+		return err
 	}
 
-	if r.rowNum >= r.query.Result().Metadata.Offset + len(r.rows) {
-		err := r.fetchNext()
-		if err != nil {
-			return err
+	for i, v := range row {
+		if r.conn != nil && r.conn.nativeTypes {
+			v, err = mapi.ConvertNative(v, r.query.Result().Schema[i].ColumnType)
+			if err != nil {
+				return err
+			}
 		}
-	}
-
-	for i, v := range r.rows[r.rowNum - r.query.Result().Metadata.Offset] {
-		if vv, ok := v.(string); ok {
+		switch vv := v.(type) {
+		case string:
 			dest[i] = []byte(vv)
-		} else {
+		case mapi.Decimal:
+			// The text of the decimal can be scanned into a string, a
+			// float64 or a mapi.Decimal without losing digits
+			if r.conn != nil && r.conn.decimalAsFloat {
+				dest[i] = vv.Float64()
+			} else {
+				dest[i] = []byte(vv.String())
+			}
+		default:
 			dest[i] = v
 		}
 	}
-	r.rowNum += 1
 
 	return nil
 }
 
-func min(a, b int) int {
-	if a < b {
-		return a
+// Reading a row can wait for the rows to arrive, or fetch the next block of
+// rows from the server. Like Stmt.mapiDo this runs in a goroutine when the
+// context of the query can be cancelled, so the command is interrupted on the
+// server, or the connection is closed, when the context is done.
+func (r *Rows) mapiDo(do func() ([]mapi.Value, error)) ([]mapi.Value, error) {
+	if r.ctx == nil || r.ctx.Done() == nil || r.conn == nil {
+		return do()
+	}
+	if err := r.ctx.Err(); err != nil {
+		return nil, err
 	}
-
-	return b
-}
-
-// This function call to FetchNext connects to the database and can potentially take a long time. Therefore
-// we want to be able to cancel it, so we run it inside a goroutine.
-func (s *Rows) mapiDo(ctx context.Context, amount int) (string, error) {
 	type res struct {
-		resultstring string;
+		row []mapi.Value
 		err error
 	}
 	c := make(chan res, 1)
-
-    go func() {
-		r, err := s.query.FetchNext(s.query.Result().Metadata.Offset, amount)
-		result := res{r, err}
-		c <- result
-		}()
-
-    select {
-    case <-ctx.Done():
-        <-c // Wait for the goroutine to return. Later we need to cancel the query on the database
-        return "", ctx.Err()
-    case result := <-c:
-        return result.resultstring, result.err
-    }
-}
-
-func (r *Rows) fetchNext() error {
-	if r.rowNum >= r.query.Result().Metadata.RowCount {
-		return io.EOF
+	done := make(chan struct{})
+
+	go func() {
+		row, err := do()
+		c <- res{row, err}
+		close(done)
+	}()
+
+	select {
+	case <-r.ctx.Done():
+		r.conn.cancel(done)
+		return nil, r.ctx.Err()
+	case result := <-c:
+		return result.row, result.err
 	}
-
-	r.query.Result().Metadata.Offset += len(r.rows)
-	end := min(r.query.Result().Metadata.RowCount, r.rowNum+mapi.MAPI_ARRAY_SIZE)
-	amount := end - r.query.Result().Metadata.Offset
-
-	res, err := r.mapiDo(context.Background(), amount)
-	if err != nil {
-		return err
-	}
-
-	r.query.StoreResult(res)
-	r.rows = convertRows(r.query.Result().Rows, r.query.Result().Metadata.ColumnCount)
-
-	return nil
 }
 
 // See https://pkg.go.dev/database/sql/driver#RowsColumnTypeLength for what to implement
@@ -159,6 +159,15 @@ func (r *Rows) ColumnTypePrecisionScale(index int) (precision, scale int64, ok b
 	}
 }
 
+// nativeScanTypes are the scan types of the MonetDB specific types with
+// NativeTypesOption
+var nativeScanTypes = map[string]reflect.Type{
+	mapi.MDB_URL:  reflect.TypeOf(&url.URL{}),
+	mapi.MDB_UUID: reflect.TypeOf(mapi.UUID{}),
+	mapi.MDB_INET: reflect.TypeOf(netip.Prefix{}),
+	mapi.MDB_JSON: reflect.TypeOf(json.RawMessage{}),
+}
+
 // See https://pkg.go.dev/database/sql/driver#RowsColumnTypeScanType for what to implement
 func (r *Rows) ColumnTypeScanType(index int) reflect.Type {
 	var scantype reflect.Type
@@ -167,10 +176,13 @@ func (r *Rows) ColumnTypeScanType(index int) reflect.Type {
 	case mapi.MDB_VARCHAR,
 		mapi.MDB_CHAR,
 		mapi.MDB_CLOB,
-		mapi.MDB_INTERVAL,
-		mapi.MDB_MONTH_INTERVAL,
-		mapi.MDB_SEC_INTERVAL :
+		mapi.MDB_INTERVAL :
 		scantype = reflect.TypeOf("")
+	case mapi.MDB_SEC_INTERVAL,
+		mapi.MDB_DAY_INTERVAL :
+		scantype = reflect.TypeOf(time.Duration(0))
+	case mapi.MDB_MONTH_INTERVAL :
+		scantype = reflect.TypeOf(mapi.MonthInterval(0))
 	case mapi.MDB_NULL :
 		scantype = reflect.TypeOf(nil)
 	case mapi.MDB_BLOB :
@@ -180,8 +192,13 @@ func (r *Rows) ColumnTypeScanType(index int) reflect.Type {
 	case mapi.MDB_REAL,
 		mapi.MDB_FLOAT :
 		scantype = reflect.TypeOf(float32(0))
-	case mapi.MDB_DECIMAL,
-		mapi.MDB_DOUBLE :
+	case mapi.MDB_DECIMAL :
+		if r.conn != nil && r.conn.decimalAsFloat {
+			scantype = reflect.TypeOf(float64(0))
+		} else {
+			scantype = reflect.TypeOf(mapi.Decimal{})
+		}
+	case mapi.MDB_DOUBLE :
 		scantype = reflect.TypeOf(float64(0))
 	case mapi.MDB_TINYINT :
 		scantype = reflect.TypeOf(int8(0))
@@ -192,21 +209,29 @@ func (r *Rows) ColumnTypeScanType(index int) reflect.Type {
 		mapi.MDB_MEDIUMINT,
 		mapi.MDB_WRD :
 		scantype = reflect.TypeOf(int32(0))
+	case mapi.MDB_HUGEINT :
+		// The values that do not fit in an int64 are returned as *big.Int,
+		// an Int128 can hold both
+		scantype = reflect.TypeOf(mapi.Int128{})
 	case mapi.MDB_BIGINT,
-		mapi.MDB_HUGEINT,
 		mapi.MDB_SERIAL,
 		mapi.MDB_LONGINT :
 		scantype = reflect.TypeOf(int64(0))
 	case mapi.MDB_DATE,
 		mapi.MDB_TIME,
 		mapi.MDB_TIMESTAMP,
-		mapi.MDB_TIMESTAMPTZ :
+		mapi.MDB_TIMESTAMPTZ,
+		mapi.MDB_TIMETZ :
 		scantype = reflect.TypeOf(time.Time{})
 	case mapi.MDB_URL,
 		mapi.MDB_UUID,
 		mapi.MDB_INET,
 		mapi.MDB_JSON :
-		scantype = reflect.TypeOf("")
+		if r.conn != nil && r.conn.nativeTypes {
+			scantype = nativeScanTypes[r.query.Result().Schema[index].ColumnType]
+		} else {
+			scantype = reflect.TypeOf("")
+		}
 	default:
 		scantype = reflect.TypeOf(nil)
 	}
@@ -215,16 +240,12 @@ func (r *Rows) ColumnTypeScanType(index int) reflect.Type {
 
 // To support the NextResultSet interface, you need to implement two functions. But the sql.Rows type
 // only provides the NextResultSet function, which uses both. See https://pkg.go.dev/database/sql#Rows.NextResultSet
-// The current implementation of mapi.ResultSet.StoreResult function does not handle multiple resultsets. So these
-// functions are strictly speaking not needed. But we provide them to document this behaviour.
+// The next resultset follows the rows of the current one in the response, so the rows that are not read
+// yet are skipped.
 func (r *Rows) HasNextResultSet() bool {
 	return r.query.HasNextResultSet()
 }
 
 func (r *Rows) NextResultSet() error {
-	err := r.query.NextResultSet()
-	if err == nil {
-		r.rows = convertRows(r.query.Result().Rows, r.query.Result().Metadata.ColumnCount)
-	}
-	return err
+	return r.query.NextResultSet()
 }
@@ -313,7 +313,7 @@ func TestColumnTypesIntegration(t *testing.T) {
 			[]int64{0, 0},
 			[]bool{false, false},
 			[]string{"DECIMAL", "DECIMAL"},
-			[]string{"float64", "float64"},
+			[]string{"Decimal", "Decimal"},
 			[]bool{true, true},
 			[]int64{18, 10},
 			[]int64{3, 5},
@@ -328,7 +328,7 @@ func TestColumnTypesIntegration(t *testing.T) {
 			[]int64{0, 0},
 			[]bool{false, false},
 			[]string{"DOUBLE", "DECIMAL"},
-			[]string{"float64", "float64"},
+			[]string{"float64", "Decimal"},
 			[]bool{false, true},
 			[]int64{0, 18},
 			[]int64{0, 3},
@@ -0,0 +1,391 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package monetdb
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"math"
+	"math/big"
+	"net"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MonetDB/MonetDB-Go/v2/mapi"
+	"github.com/MonetDB/MonetDB-Go/v2/mapitest"
+)
+
+// columnServer answers a PREPARE with a prepared statement with id 1 and the
+// given parameter types, and every other query with the table
+func columnServer(t *testing.T, params []string, table mapitest.Table) *mapitest.Server {
+	srv := mapitest.NewServer(mapitest.HandlerFunc(func(r *mapitest.Request) mapitest.Reply {
+		switch {
+		case strings.HasPrefix(r.Statement, "PREPARE"):
+			return mapitest.Prepared{ID: 1, Params: params}
+		case strings.HasPrefix(r.Statement, "EXEC"):
+			return mapitest.Update{Count: 1, LastID: -1}
+		case strings.HasPrefix(r.Statement, "SET"):
+			return mapitest.Schema{}
+		default:
+			return table
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// lastStatement returns the last statement that the server received
+func lastStatement(srv *mapitest.Server) string {
+	statements := srv.Statements()
+	if len(statements) == 0 {
+		return ""
+	}
+	return statements[len(statements)-1]
+}
+
+// decimalServer answers every query with a decimal that does not fit in a
+// float64, a PREPARE has a decimal placeholder
+func decimalServer(t *testing.T) *mapitest.Server {
+	return columnServer(t, []string{"decimal(18,4)"},
+		mapitest.Table{Columns: []string{"amount"}, Types: []string{"decimal"}, Rows: [][]string{{"12345678901234.5678"}}})
+}
+
+func TestDecimalColumns(t *testing.T) {
+	t.Run("Verify decimals are exact", func(t *testing.T) {
+		db := openPool(t, decimalServer(t))
+		var s string
+		if err := db.QueryRow("SELECT amount FROM t").Scan(&s); err != nil {
+			t.Fatal(err)
+		}
+		if s != "12345678901234.5678" {
+			t.Errorf("Unexpected string: %s", s)
+		}
+		var d mapi.Decimal
+		if err := db.QueryRow("SELECT amount FROM t").Scan(&d); err != nil {
+			t.Fatal(err)
+		}
+		if d.String() != s || d.Scale() != 4 {
+			t.Errorf("Unexpected decimal: %s", d)
+		}
+	})
+
+	t.Run("Verify decimals are scanned into big.Rat", func(t *testing.T) {
+		db := openPool(t, decimalServer(t))
+		r := new(big.Rat)
+		if err := db.QueryRow("SELECT amount FROM t").Scan(mapi.RatScanner{R: r}); err != nil {
+			t.Fatal(err)
+		}
+		expected, _ := new(big.Rat).SetString("12345678901234.5678")
+		if r.Cmp(expected) != 0 {
+			t.Errorf("Unexpected rat: %s", r)
+		}
+	})
+
+	t.Run("Verify decimals as float", func(t *testing.T) {
+		srv := decimalServer(t)
+		connector, err := NewConnector(srv.DSN(), DecimalAsFloatOption(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		db := sql.OpenDB(connector)
+		defer db.Close()
+		var v interface{}
+		if err := db.QueryRow("SELECT amount FROM t").Scan(&v); err != nil {
+			t.Fatal(err)
+		}
+		if f, ok := v.(float64); !ok || f != 12345678901234.5678 {
+			t.Errorf("Unexpected value: %#v", v)
+		}
+	})
+
+	t.Run("Verify decimal argument", func(t *testing.T) {
+		srv := decimalServer(t)
+		db := openPool(t, srv)
+		d, _ := mapi.ParseDecimal("0.1000")
+		stmt, err := db.Prepare("INSERT INTO t VALUES (?)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stmt.Close()
+		if _, err := stmt.Exec(d); err != nil {
+			t.Fatal(err)
+		}
+		if statement := lastStatement(srv); statement != "EXEC 1 (0.1000)" {
+			t.Errorf("Unexpected statement: %s", statement)
+		}
+	})
+}
+
+func TestHugeintColumns(t *testing.T) {
+	const max = "170141183460469231731687303715884105727"
+	srv := columnServer(t, []string{"hugeint", "bigint"}, mapitest.Table{Columns: []string{"h"}, Types: []string{"hugeint"}, Rows: [][]string{{"1"}, {max}}})
+	db := openPool(t, srv)
+
+	t.Run("Verify large values are returned as big.Int", func(t *testing.T) {
+		rows, err := db.Query("SELECT h FROM t")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		types, err := rows.ColumnTypes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if scanType := types[0].ScanType(); scanType != reflect.TypeOf(mapi.Int128{}) {
+			t.Errorf("Unexpected scan type: %v", scanType)
+		}
+		var values []interface{}
+		for rows.Next() {
+			var v interface{}
+			if err := rows.Scan(&v); err != nil {
+				t.Fatal(err)
+			}
+			values = append(values, v)
+		}
+		if len(values) != 2 || values[0] != int64(1) {
+			t.Fatalf("Unexpected values: %v", values)
+		}
+		if b, ok := values[1].(*big.Int); !ok || b.String() != max {
+			t.Errorf("Unexpected value: %#v", values[1])
+		}
+		var h mapi.Int128
+		if err := h.Scan(values[1]); err != nil || h.String() != max {
+			t.Errorf("Unexpected Int128: %v, %v", h, err)
+		}
+	})
+
+	t.Run("Verify large arguments", func(t *testing.T) {
+		stmt, err := db.Prepare("INSERT INTO t VALUES (?, ?)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stmt.Close()
+		b, _ := new(big.Int).SetString(max, 10)
+		if _, err := stmt.Exec(b, uint64(math.MaxUint64)); err != nil {
+			t.Fatal(err)
+		}
+		expected := "EXEC 1 (" + max + ", 18446744073709551615)"
+		if statement := lastStatement(srv); statement != expected {
+			t.Errorf("Unexpected statement: %s", statement)
+		}
+	})
+}
+
+func TestIntervalColumns(t *testing.T) {
+	srv := columnServer(t, []string{"sec_interval(13,3)", "month_interval(3,0)"}, mapitest.Table{
+		Columns: []string{"s", "d", "m"},
+		Types:   []string{"sec_interval", "day_interval", "month_interval"},
+		Rows:    [][]string{{"-1.500", "172800.000", "14"}},
+	})
+	db := openPool(t, srv)
+
+	t.Run("Verify intervals are scanned", func(t *testing.T) {
+		var s, d time.Duration
+		var m mapi.MonthInterval
+		if err := db.QueryRow("SELECT s, d, m FROM t").Scan(&s, &d, &m); err != nil {
+			t.Fatal(err)
+		}
+		if s != -1500*time.Millisecond || d != 48*time.Hour || m != 14 {
+			t.Errorf("Unexpected intervals: %v, %v, %v", s, d, m)
+		}
+	})
+
+	t.Run("Verify interval arguments", func(t *testing.T) {
+		stmt, err := db.Prepare("INSERT INTO t VALUES (?, ?)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stmt.Close()
+		if _, err := stmt.Exec(-1500*time.Millisecond, mapi.MonthInterval(14)); err != nil {
+			t.Fatal(err)
+		}
+		expected := "EXEC 1 (INTERVAL '-1.500' SECOND, INTERVAL '14' MONTH)"
+		if statement := lastStatement(srv); statement != expected {
+			t.Errorf("Unexpected statement: %s", statement)
+		}
+	})
+}
+
+func TestNativeTypes(t *testing.T) {
+	const id = "26d7a80b-7538-4682-a49a-9d0f9676b765"
+	srv := columnServer(t, []string{"uuid", "inet"}, mapitest.Table{
+		Columns: []string{"u", "i", "l", "j"},
+		Types:   []string{"uuid", "inet", "url", "json"},
+		Rows:    [][]string{{`"` + id + `"`, `"10.0.0.0/8"`, `"https://www.monetdb.org/"`, `"{\"a\": 1}"`}},
+	})
+
+	t.Run("Verify text by default", func(t *testing.T) {
+		db := openPool(t, srv)
+		var u, i, l, j string
+		if err := db.QueryRow("SELECT u, i, l, j FROM t").Scan(&u, &i, &l, &j); err != nil {
+			t.Fatal(err)
+		}
+		if u != id || i != "10.0.0.0/8" || l != "https://www.monetdb.org/" || j != `{"a": 1}` {
+			t.Errorf("Unexpected values: %s, %s, %s, %s", u, i, l, j)
+		}
+		var doc struct{ A int }
+		if err := db.QueryRow("SELECT u, i, l, j FROM t").Scan(&u, &i, &l, &mapi.JSON{V: &doc}); err != nil || doc.A != 1 {
+			t.Errorf("Unexpected document: %v, %v", doc, err)
+		}
+	})
+
+	t.Run("Verify native types", func(t *testing.T) {
+		connector, err := NewConnector(srv.DSN(), NativeTypesOption(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		db := sql.OpenDB(connector)
+		defer db.Close()
+		rows, err := db.Query("SELECT u, i, l, j FROM t")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		types, err := rows.ColumnTypes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if scanType := types[1].ScanType(); scanType != reflect.TypeOf(netip.Prefix{}) {
+			t.Errorf("Unexpected scan type: %v", scanType)
+		}
+		if !rows.Next() {
+			t.Fatal(rows.Err())
+		}
+		var u mapi.UUID
+		var i netip.Prefix
+		var l *url.URL
+		var j json.RawMessage
+		if err := rows.Scan(&u, &i, &l, &j); err != nil {
+			t.Fatal(err)
+		}
+		if u.String() != id || i.Bits() != 8 || l.Host != "www.monetdb.org" || string(j) != `{"a": 1}` {
+			t.Errorf("Unexpected values: %v, %v, %v, %s", u, i, l, j)
+		}
+	})
+
+	t.Run("Verify native arguments", func(t *testing.T) {
+		db := openPool(t, srv)
+		stmt, err := db.Prepare("INSERT INTO t VALUES (?, ?)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stmt.Close()
+		u, _ := mapi.ParseUUID(id)
+		if _, err := stmt.Exec(u, net.ParseIP("10.0.0.1")); err != nil {
+			t.Fatal(err)
+		}
+		expected := "EXEC 1 (CAST('" + id + "' AS uuid), CAST('10.0.0.1' AS inet))"
+		if statement := lastStatement(srv); statement != expected {
+			t.Errorf("Unexpected statement: %s", statement)
+		}
+	})
+}
+
+func TestBlobColumns(t *testing.T) {
+	srv := columnServer(t, []string{"blob"}, mapitest.Table{Columns: []string{"b"}, Types: []string{"blob"}, Rows: [][]string{{"00FF27"}, {""}}})
+	db := openPool(t, srv)
+
+	t.Run("Verify blobs are decoded", func(t *testing.T) {
+		rows, err := db.Query("SELECT b FROM t")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		var values [][]byte
+		for rows.Next() {
+			var b []byte
+			if err := rows.Scan(&b); err != nil {
+				t.Fatal(err)
+			}
+			values = append(values, b)
+		}
+		if len(values) != 2 || !bytes.Equal(values[0], []byte{0, 0xff, '\''}) || len(values[1]) != 0 {
+			t.Errorf("Unexpected values: %v", values)
+		}
+	})
+
+	t.Run("Verify blob reader", func(t *testing.T) {
+		var b mapi.Blob
+		if err := db.QueryRow("SELECT b FROM t").Scan(&b); err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(b.Reader())
+		if err != nil || !bytes.Equal(data, []byte{0, 0xff, '\''}) {
+			t.Errorf("Unexpected data: %v, %v", data, err)
+		}
+	})
+
+	t.Run("Verify reader argument", func(t *testing.T) {
+		stmt, err := db.Prepare("INSERT INTO t VALUES (?)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stmt.Close()
+		if _, err := stmt.Exec(bytes.NewReader([]byte{0, 0xff, '\''})); err != nil {
+			t.Fatal(err)
+		}
+		if statement := lastStatement(srv); statement != "EXEC 1 (CAST('00ff27' AS blob))" {
+			t.Errorf("Unexpected statement: %s", statement)
+		}
+	})
+}
+
+func TestTemporalColumns(t *testing.T) {
+	srv := columnServer(t, []string{"timestamptz(7,0)", "timestamp(7,0)"}, mapitest.Table{
+		Columns: []string{"t", "tz", "ts", "tstz"},
+		Types:   []string{"time", "timetz", "timestamp", "timestamptz"},
+		Rows:    [][]string{{"10:20:30.000123", "10:20:30.5+01:00", "2001-01-02 10:20:30.123456", "2001-01-02 10:20:30.123456+01:00"}},
+	})
+	loc := time.FixedZone("UTC-5", -5*3600)
+	connector, err := NewConnector(srv.DSN(), TimezoneOption(loc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	t.Run("Verify fractional seconds and time zones", func(t *testing.T) {
+		var tm mapi.Time
+		var tz, ts, tstz time.Time
+		if err := db.QueryRow("SELECT t, tz, ts, tstz FROM t").Scan(&tm, &tz, &ts, &tstz); err != nil {
+			t.Fatal(err)
+		}
+		if tm.String() != "10:20:30.000123" {
+			t.Errorf("Unexpected time: %v", tm)
+		}
+		if _, offset := tz.Zone(); offset != 3600 || tz.Nanosecond() != 500000000 {
+			t.Errorf("Unexpected time with time zone: %v", tz)
+		}
+		if ts.Nanosecond() != 123456000 || ts.Hour() != 10 {
+			t.Errorf("Unexpected timestamp: %v", ts)
+		}
+		if tstz.Location() != loc || tstz.Hour() != 4 || tstz.Nanosecond() != 123456000 {
+			t.Errorf("Unexpected timestamp with time zone: %v", tstz)
+		}
+	})
+
+	t.Run("Verify time arguments", func(t *testing.T) {
+		stmt, err := db.Prepare("INSERT INTO t VALUES (?, ?)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stmt.Close()
+		tm := time.Date(2001, time.January, 2, 10, 20, 30, 123456789, loc)
+		if _, err := stmt.Exec(tm, tm); err != nil {
+			t.Fatal(err)
+		}
+		literal := "TIMESTAMP WITH TIME ZONE '2001-01-02 10:20:30.123456-05:00'"
+		expected := "EXEC 1 (" + literal + ", CAST(" + literal + " AS timestamp))"
+		if statement := lastStatement(srv); statement != expected {
+			t.Errorf("Unexpected statement: %s", statement)
+		}
+	})
+}
@@ -33,14 +33,30 @@ func executeStmt(c *Conn, query string) error {
 	return err
 }
 
+// Close deallocates the prepared statement on the server. To save a round
+// trip this is done together with the next command on the connection. Rows
+// that are still open stay readable.
 func (s *Stmt) Close() error {
-	// TODO: check if this is correct, the pool should handle the connections
+	if s.conn != nil {
+		s.query.Release()
+	}
 	s.conn = nil
 	return nil
 }
 
+// NumInput returns the number of placeholders of a prepared statement, or
+// -1 when the server did not describe them
 func (s *Stmt) NumInput() int {
-	return -1
+	if !s.isPreparedStatement || s.query.Result() == nil || s.query.Params() == nil {
+		return -1
+	}
+	return len(s.query.Params())
+}
+
+// Parameters returns the types of the placeholders of a prepared statement.
+// The arguments are converted to these types before they are sent.
+func (s *Stmt) Parameters() []mapi.Parameter {
+	return s.query.Params()
 }
 
 // Deprecated: Use ExecContext instead
@@ -51,25 +67,36 @@ func (s *Stmt) Exec(args []driver.Value) (driver.Result, error) {
 }
 
 // This function executes a mapi command inside a goroutine. This makes it possible to cancel
-// the command when the context is cancelled. At this point in time MonetDB does not support cancelling
-// a running query. This feature is planned for the next release. When that comes available, we will add
-// a function call that cancels the query when a timeout occurs before it is finished.
-func (s *Stmt) mapiDo(ctx context.Context, args []driver.NamedValue) (string, error) {
+// the command when the context is cancelled. The running query is then interrupted on the server,
+// and we wait for the goroutine to return, so the connection is ready for the next command.
+func (s *Stmt) mapiDo(ctx context.Context, do func() (string, error)) (string, error) {
 	type res struct {
 		resultstring string;
 		err error
 	}
 	c := make(chan res, 1)
+	done := make(chan struct{})
+
+	// Nothing is sent yet, so database/sql can safely retry the statement on
+	// another connection
+	if s.conn != nil && !s.conn.IsValid() {
+		return "", driver.ErrBadConn
+	}
+	if s.conn != nil && s.conn.mapi != nil {
+		s.conn.setFileTransferHandlers(ctx)
+		s.conn.setDeadline(ctx)
+	}
 
     go func() {
-		r, err := s.exec(args)
+		r, err := do()
 		result := res{r, err}
 		c <- result
+		close(done)
 		}()
 
     select {
     case <-ctx.Done():
-        <-c // Wait for the goroutine to return. Later we need to cancel the query on the database
+        s.conn.cancel(done)
         return "", ctx.Err()
     case result := <-c:
         return result.resultstring, result.err
@@ -78,31 +105,27 @@ func (s *Stmt) mapiDo(ctx context.Context, args []driver.NamedValue) (string, er
 
 func (s *Stmt) execResult(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
 	res := newResult()
-	r, err := s.mapiDo(ctx, args)
+	r, err := s.mapiDo(ctx, func() (string, error) {
+		return s.exec(ctx, args)
+	})
 	if err != nil {
 		res.err = err
 		return res, res.err
 	}
 
 	err = s.query.StoreResult(r)
-	res.lastInsertId = s.query.Result().Metadata.LastRowId
-	res.rowsAffected = s.query.Result().Metadata.RowCount
+	// An empty response has no result set
+	if rs := s.query.Result(); rs != nil {
+		res.lastInsertId = rs.Metadata.LastRowId
+		res.rowsAffected = rs.Metadata.RowCount
+	}
+	// The rows that were not in the reply are not needed
+	s.query.Close()
 	res.err = err
 
 	return res, res.err
 }
 
-func convertRows(rows [][]mapi.Value, columncount int)([][]driver.Value) {
-	res := make([][]driver.Value, len(rows))
-	for i, row := range rows {
-		res[i] = make([]driver.Value, columncount)
-		for j, col := range row {
-			res[i][j] = col
-		}
-	}
-	return res
-}
-
 func convertParamValues(args []driver.Value)([]mapi.Value) {
 	res := make([]mapi.Value, len(args))
 	for i, arg := range args {
@@ -151,29 +174,35 @@ func (s *Stmt) Query(args []driver.Value) (driver.Rows, error) {
 }
 
 func (s *Stmt) queryResult(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
-	rows := newRows(s.query)
-	r, err := s.mapiDo(ctx, args)
-	if err != nil {
-		return rows, err
-	}
-
-	err = s.query.StoreResult(r)
-	if err != nil {
-		return rows, err
-	}
-	// We have gotten the first batch of the resultset. The RowCount is the total number of rows in the result.
-	// But we have only at most mapi.MAPI_ARRAY_SIZE rows available.
-	rows.rows = convertRows(s.query.Result().Rows, s.query.Result().Metadata.ColumnCount)
-
+	rows := newRows(ctx, s.conn, s.query)
+	// Only the first part of the response is read here, the rows are read
+	// by the Next function of the rows while they arrive
+	_, err := s.mapiDo(ctx, func() (string, error) {
+		return "", s.open(ctx, args)
+	})
 	return rows, err
 }
 
-func (s *Stmt) exec(args []driver.NamedValue) (string, error) {
+// prepare prepares the statement on the server, when this is needed before
+// it can be executed
+func (s *Stmt) prepare(ctx context.Context) error {
 	if ((s.isPreparedStatement && (s.query.Result() == nil)) || ((s.query.Result() != nil) && (s.query.Result().Metadata.ExecId == -1))) {
 		err := s.query.PrepareQuery()
 		if err != nil {
-			return "", err
+			return err
 		}
+		// Do not start the next command when the statement was cancelled
+		// while it was being prepared
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Stmt) exec(ctx context.Context, args []driver.NamedValue) (string, error) {
+	if err := s.prepare(ctx); err != nil {
+		return "", err
 	}
 
 	if len(args) != 0 {
@@ -190,6 +219,27 @@ func (s *Stmt) exec(args []driver.NamedValue) (string, error) {
 	}
 }
 
+// open executes the statement like exec, but leaves the rows of the result
+// to be read while they arrive
+func (s *Stmt) open(ctx context.Context, args []driver.NamedValue) error {
+	if err := s.prepare(ctx); err != nil {
+		return err
+	}
+
+	if len(args) != 0 {
+		if s.isPreparedStatement {
+			queryParams := convertParamValues(paramValuesList(args))
+			return s.query.OpenPreparedQuery(queryParams)
+		} else {
+			queryParamsNames := paramNamesList(args)
+			queryParams := convertParamValues(paramValuesList(args))
+			return s.query.OpenNamedQuery(queryParamsNames, queryParams)
+		}
+	} else {
+		return s.query.OpenQuery()
+	}
+}
+
 func (s *Stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
 	res, err := s.execResult(ctx, args)
 	return res, err
@@ -201,6 +251,5 @@ func (s *Stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driv
 }
 
 func (s *Stmt) CheckNamedValue(arg *driver.NamedValue) error {
-	_, err := mapi.ConvertToMonet(arg.Value)
-	return err
+	return checkNamedValue(arg)
 }
\ No newline at end of file
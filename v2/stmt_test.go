@@ -0,0 +1,76 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package monetdb
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/MonetDB/MonetDB-Go/v2/mapitest"
+)
+
+func TestPreparedStatement(t *testing.T) {
+	srv := mapitest.NewServer(mapitest.HandlerFunc(func(r *mapitest.Request) mapitest.Reply {
+		if strings.HasPrefix(r.Statement, "PREPARE") {
+			return mapitest.Prepared{ID: 7, Params: []string{"decimal(10,2)", "date"}}
+		}
+		return mapitest.Update{Count: 1, LastID: -1}
+	}))
+	defer srv.Close()
+	db := openPool(t, srv)
+
+	stmt, err := db.Prepare("INSERT INTO t VALUES (?, ?)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	t.Run("Verify number of arguments is checked", func(t *testing.T) {
+		if _, err := stmt.Exec(1); err == nil || !strings.Contains(err.Error(), "expected 2 arguments") {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Verify arguments are converted to the parameter types", func(t *testing.T) {
+		if _, err := stmt.Exec(1, nil); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := stmt.Exec("2.50", "2021-02-03"); err != nil {
+			t.Fatal(err)
+		}
+		expected := []string{
+			"EXEC 7 (CAST(1 AS decimal(10,2)), CAST(NULL AS date))",
+			"EXEC 7 (CAST('2.50' AS decimal(10,2)), CAST('2021-02-03' AS date))",
+		}
+		statements := srv.Statements()
+		if len(statements) < 2 || strings.Join(statements[len(statements)-2:], "\n") != strings.Join(expected, "\n") {
+			t.Errorf("Unexpected statements: %q", statements)
+		}
+	})
+
+	t.Run("Verify parameter types", func(t *testing.T) {
+		conn, err := db.Conn(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		err = conn.Raw(func(driverConn interface{}) error {
+			s, err := driverConn.(*Conn).Prepare("INSERT INTO t VALUES (?, ?)")
+			if err != nil {
+				return err
+			}
+			defer s.Close()
+			params := s.(*Stmt).Parameters()
+			if s.NumInput() != 2 || params[0].SQLType() != "decimal(10,2)" || params[1].SQLType() != "date" {
+				t.Errorf("Unexpected parameters: %v", params)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}